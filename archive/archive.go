@@ -0,0 +1,138 @@
+// Package archive provides access to the TOC and data blocks of pg_dump
+// archives, either randomly through an io.ReaderAt or sequentially.
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var ErrNoData = errors.New("TOC entry has no data")
+var ErrOffsetNotSet = errors.New("TOC entry data offset not set")
+var ErrUnknownDumpID = errors.New("unknown dump ID")
+var ErrBlockMismatch = errors.New("data block does not match TOC entry")
+var ErrUnsupportedFormat = errors.New("unsupported archive format")
+
+// Archive is a custom format archive whose header and TOC have been parsed
+// once, allowing data blocks to be read directly at their TOC offsets.
+type Archive struct {
+	r    io.ReaderAt
+	byID map[int]int
+	// Entries is the table of contents.
+	Entries []metadata.TOCEntry
+	// Metadata is the archive header.
+	Metadata metadata.Metadata
+	// DataStart is the offset of the first byte after the TOC.
+	DataStart int64
+	// Size is the size of the archive in bytes.
+	Size int64
+}
+
+// Open parses the header and TOC of the archive in r, which is size bytes long.
+func Open(r io.ReaderAt, size int64) (*Archive, error) {
+	counter := &metadata.CountingReader{R: io.NewSectionReader(r, 0, size)}
+	br := bufio.NewReader(counter)
+
+	meta, entries, err := parse(br)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Archive{
+		r:         r,
+		byID:      indexEntries(entries),
+		Entries:   entries,
+		Metadata:  meta,
+		DataStart: counter.N - int64(br.Buffered()),
+		Size:      size,
+	}, nil
+}
+
+// Entry returns the TOC entry with dumpID, or nil if there is none.
+func (a *Archive) Entry(dumpID int) *metadata.TOCEntry {
+	if i, ok := a.byID[dumpID]; ok {
+		return &a.Entries[i]
+	}
+
+	return nil
+}
+
+// Block reads the header of the data block at offset.
+func (a *Archive) Block(offset int64) (*Block, error) {
+	if a.Metadata.Format != "CUSTOM" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, a.Metadata.Format)
+	}
+	if offset < a.DataStart || offset >= a.Size {
+		return nil, fmt.Errorf("%w: offset %d outside data region", metadata.ErrInvalidOffset, offset)
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(a.r, offset, a.Size-offset))
+
+	return readBlock(&a.Metadata, br, offset)
+}
+
+// EntryBlock reads the header of the data block of entry, jumping straight to
+// the offset recorded in the TOC.
+func (a *Archive) EntryBlock(entry *metadata.TOCEntry) (*Block, error) {
+	switch entry.DataState {
+	case metadata.OffsetPosSet:
+	case metadata.OffsetPosNotSet:
+		return nil, fmt.Errorf("%w: dumpId=%d", ErrOffsetNotSet, entry.DumpID)
+	default:
+		return nil, fmt.Errorf("%w: dumpId=%d", ErrNoData, entry.DumpID)
+	}
+
+	block, err := a.Block(entry.DataOffset)
+	if err != nil {
+		return nil, err
+	}
+	if block.DumpID != entry.DumpID {
+		return nil, fmt.Errorf("%w: block at offset %d has dumpId=%d, expected=%d",
+			ErrBlockMismatch, entry.DataOffset, block.DumpID, entry.DumpID)
+	}
+
+	return block, nil
+}
+
+// Data returns the decompressed data of the entry with dumpID.
+func (a *Archive) Data(dumpID int) (io.ReadCloser, error) {
+	entry := a.Entry(dumpID)
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownDumpID, dumpID)
+	}
+
+	block, err := a.EntryBlock(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return block.Data()
+}
+
+// parse reads the header and TOC from br, leaving it positioned after the TOC.
+func parse(br *bufio.Reader) (metadata.Metadata, []metadata.TOCEntry, error) {
+	meta, err := metadata.NewMetadata(br)
+	if err != nil {
+		return meta, nil, fmt.Errorf("err reading metadata: %w", err)
+	}
+
+	entries, err := meta.ReadTOC(br)
+	if err != nil {
+		return meta, entries, fmt.Errorf("err reading TOC: %w", err)
+	}
+
+	return meta, entries, nil
+}
+
+func indexEntries(entries []metadata.TOCEntry) map[int]int {
+	byID := make(map[int]int, len(entries))
+	for i := range entries {
+		byID[entries[i].DumpID] = i
+	}
+
+	return byID
+}
@@ -0,0 +1,148 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+func testArchive(compression int) *dumptest.Archive {
+	return &dumptest.Archive{
+		Database:    "shop",
+		VMin:        14,
+		Compression: compression,
+		ChunkSize:   16,
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop", Defn: "CREATE TABLE public.orders (id integer);"},
+			{
+				Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop",
+				CopyStmt: "COPY public.orders (id) FROM stdin;\n",
+				Data:     []byte(strings.Repeat("1\n2\n3\n", 20)),
+				Deps:     []int{1},
+			},
+			{
+				Tag: "customers", Desc: "TABLE DATA", Namespace: "public", Owner: "shop",
+				CopyStmt: "COPY public.customers (name) FROM stdin;\n",
+				Data:     []byte("alice\nbob\n"),
+			},
+			{Tag: "orders_pkey", Desc: "CONSTRAINT", Namespace: "public", Owner: "shop", Section: dumptest.SectionPostData},
+		},
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	data := testArchive(0).Bytes()
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.Entries) != 4 || a.Metadata.TOCCount != 4 {
+		t.Fatalf("expected 4 entries, got=%d", len(a.Entries))
+	}
+
+	entry := a.Entry(2)
+	if entry == nil {
+		t.Fatal("expected entry 2")
+	}
+	if entry.QualifiedName() != "public.orders" || entry.Desc != "TABLE DATA" || entry.Section != "DATA" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.TableAM != "heap" || entry.Owner != "shop" || len(entry.Dependencies) != 1 || entry.Dependencies[0] != 1 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.DataState != metadata.OffsetPosSet || entry.DataOffset < a.DataStart {
+		t.Errorf("unexpected offset: state=%d offset=%d dataStart=%d", entry.DataState, entry.DataOffset, a.DataStart)
+	}
+	if a.Entry(1).HasData() || a.Entry(4).Section != "POST-DATA" {
+		t.Errorf("unexpected entries: %+v %+v", a.Entry(1), a.Entry(4))
+	}
+}
+
+func TestData(t *testing.T) {
+	t.Parallel()
+
+	for _, compression := range []int{0, -1} {
+		built := testArchive(compression)
+		data := built.Bytes()
+
+		a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Read out of order to exercise the random access.
+		for _, dumpID := range []int{3, 2} {
+			r, err := a.Data(dumpID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = r.Close()
+
+			if exp := built.Entries[dumpID-1].Data; !bytes.Equal(got, exp) {
+				t.Errorf("compression=%d dumpId=%d: expected=%q, got=%q", compression, dumpID, exp, got)
+			}
+		}
+	}
+}
+
+func TestDataErrors(t *testing.T) {
+	t.Parallel()
+
+	data := testArchive(0).Bytes()
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Data(1); !errors.Is(err, archive.ErrNoData) {
+		t.Errorf("expected=%v, got=%v", archive.ErrNoData, err)
+	}
+	if _, err := a.Data(99); !errors.Is(err, archive.ErrUnknownDumpID) {
+		t.Errorf("expected=%v, got=%v", archive.ErrUnknownDumpID, err)
+	}
+
+	piped := testArchive(0)
+	piped.Piped = true
+	data = piped.Bytes()
+	a, err = archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Data(2); !errors.Is(err, archive.ErrOffsetNotSet) {
+		t.Errorf("expected=%v, got=%v", archive.ErrOffsetNotSet, err)
+	}
+}
+
+func TestDataTruncated(t *testing.T) {
+	t.Parallel()
+
+	data := testArchive(0).Bytes()
+	data = data[:len(data)-8]
+
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := a.Data(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, metadata.ErrNeedMoreData) {
+		t.Errorf("expected=%v, got=%v", metadata.ErrNeedMoreData, err)
+	}
+}
@@ -0,0 +1,229 @@
+package archive
+
+import (
+	"bufio"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var ErrUnexpectedBlock = errors.New("unexpected block type")
+var ErrUnsupportedCompression = errors.New("unsupported compression")
+var ErrInvalidChunk = errors.New("invalid chunk length")
+
+// Block types of custom format data blocks.
+const (
+	BlockData  = 1
+	BlockBlobs = 3
+)
+
+// Block is a data block of a custom format archive: a type byte and dump ID
+// followed by length-prefixed chunks, terminated by an empty chunk. Blob
+// blocks hold a sequence of blobs, each prefixed by its OID and terminated by
+// an empty chunk, ending with a zero OID.
+type Block struct {
+	meta   *metadata.Metadata
+	r      *bufio.Reader
+	chunks *chunkReader
+	// Offset is the position of the block's type byte in the archive.
+	Offset int64
+	// Type is BlockData or BlockBlobs.
+	Type int
+	// DumpID is the TOC entry the block belongs to.
+	DumpID int
+	done   bool
+}
+
+// readBlock reads a block header from r, which is positioned at offset.
+func readBlock(meta *metadata.Metadata, r *bufio.Reader, offset int64) (*Block, error) {
+	blockType, err := metadata.ReadExactInt(r, 1)
+	if err != nil {
+		return nil, err
+	}
+	if blockType != BlockData && blockType != BlockBlobs {
+		return nil, fmt.Errorf("%w: %d at offset %d", ErrUnexpectedBlock, blockType, offset)
+	}
+
+	dumpID, err := meta.ReadInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if dumpID <= 0 || dumpID > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: dumpId=%d at offset %d", metadata.ErrInvalidTOC, dumpID, offset)
+	}
+
+	return &Block{
+		meta:   meta,
+		r:      r,
+		Offset: offset,
+		Type:   int(blockType),
+		DumpID: int(dumpID),
+	}, nil
+}
+
+// Raw returns the concatenated chunk payloads of a data block, as stored.
+func (b *Block) Raw() (io.Reader, error) {
+	if b.Type != BlockData {
+		return nil, fmt.Errorf("%w: %d is not a data block", ErrUnexpectedBlock, b.Type)
+	}
+
+	if b.chunks == nil {
+		b.chunks = &chunkReader{meta: b.meta, r: b.r}
+	}
+
+	return b.chunks, nil
+}
+
+// Data returns the decompressed contents of a data block.
+func (b *Block) Data() (io.ReadCloser, error) {
+	raw, err := b.Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	return decompress(b.meta, raw)
+}
+
+// NextBlob advances to the next blob of a blob block, returning its OID and
+// decompressed contents, or io.EOF after the last blob.
+func (b *Block) NextBlob() (int64, io.ReadCloser, error) {
+	oid, err := b.nextBlob()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	data, err := decompress(b.meta, b.chunks)
+	if err != nil {
+		return oid, nil, err
+	}
+
+	return oid, data, nil
+}
+
+// nextBlob reads past the rest of the current blob and the OID of the next
+// one, leaving its chunks to be read from b.chunks.
+func (b *Block) nextBlob() (int64, error) {
+	if b.Type != BlockBlobs {
+		return 0, fmt.Errorf("%w: %d is not a blob block", ErrUnexpectedBlock, b.Type)
+	}
+	if b.done {
+		return 0, io.EOF
+	}
+
+	if b.chunks != nil {
+		if _, err := io.Copy(io.Discard, b.chunks); err != nil {
+			return 0, err
+		}
+	}
+
+	oid, err := b.meta.ReadInt(b.r)
+	if err != nil {
+		return 0, err
+	}
+	if oid == 0 {
+		b.done = true
+		return 0, io.EOF
+	}
+
+	b.chunks = &chunkReader{meta: b.meta, r: b.r}
+
+	return oid, nil
+}
+
+// skip reads past the rest of the block. Blobs are skipped chunk by chunk
+// without decompressing them, so blocks compressed with an unsupported
+// algorithm can be skipped too.
+func (b *Block) skip() error {
+	switch b.Type {
+	case BlockData:
+		raw, err := b.Raw()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(io.Discard, raw)
+		return err
+	default:
+		for {
+			_, err := b.nextBlob()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// chunkReader reads the payloads of a sequence of length-prefixed chunks up
+// to the terminating empty chunk.
+type chunkReader struct {
+	meta      *metadata.Metadata
+	r         *bufio.Reader
+	remaining int64
+	done      bool
+	buf       [1]byte
+}
+
+// ReadByte lets decompressors read exactly up to the end of their stream
+// rather than buffering past it.
+func (c *chunkReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(c, c.buf[:]); err != nil {
+		return 0, err
+	}
+
+	return c.buf[0], nil
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+
+		length, err := c.meta.ReadInt(c.r)
+		if err != nil {
+			return 0, err
+		}
+		if length < 0 {
+			return 0, fmt.Errorf("%w: %d", ErrInvalidChunk, length)
+		}
+		if length == 0 {
+			c.done = true
+			return 0, io.EOF
+		}
+		c.remaining = length
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if errors.Is(err, io.EOF) {
+		return n, metadata.ErrNeedMoreData
+	}
+
+	return n, err
+}
+
+// decompress wraps r according to the archive's compression algorithm.
+func decompress(meta *metadata.Metadata, r io.Reader) (io.ReadCloser, error) {
+	switch algorithm := meta.CompressionAlgorithm(); algorithm {
+	case "none":
+		return io.NopCloser(r), nil
+	case "gzip":
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("err decompressing data: %w", err)
+		}
+		return zr, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCompression, algorithm)
+	}
+}
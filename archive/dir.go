@@ -0,0 +1,158 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var ErrDataFileNotFound = errors.New("data file not found")
+
+// TOCFileName is the file holding the header and TOC of a directory format
+// dump.
+const TOCFileName = "toc.dat"
+
+// Dir is a dump whose header and TOC have been parsed, with the data of each
+// entry kept in a file of its own: a directory or tar format dump, or a legacy
+// FILE format archive (pg_dump -Ff before PostgreSQL 9.1).
+type Dir struct {
+	fsys fs.FS
+	byID map[int]int
+	// Entries is the table of contents.
+	Entries []metadata.TOCEntry
+	// Metadata is the archive header.
+	Metadata metadata.Metadata
+}
+
+// OpenDir parses the toc.dat of the directory format dump at the root of
+// fsys, such as an os.DirFS of an unpacked dump. The members of a tar format
+// dump are laid out the same way, so fsys may hold one of those too.
+func OpenDir(fsys fs.FS) (*Dir, error) {
+	return openDir(fsys, TOCFileName, "DIRECTORY", "TAR")
+}
+
+// OpenFiles parses the FILE format archive name in fsys. The archive holds
+// only the header and TOC; pg_dump wrote the data files it names into the
+// working directory, so they are looked for in the root of fsys, normally the
+// directory holding the archive.
+func OpenFiles(fsys fs.FS, name string) (*Dir, error) {
+	return openDir(fsys, name, "FILE")
+}
+
+func openDir(fsys fs.FS, name string, formats ...string) (*Dir, error) {
+	toc, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("err opening %s: %w", name, err)
+	}
+	defer toc.Close()
+
+	meta, entries, err := parse(bufio.NewReader(toc))
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(formats, meta.Format) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, meta.Format)
+	}
+
+	return &Dir{
+		fsys:     fsys,
+		byID:     indexEntries(entries),
+		Entries:  entries,
+		Metadata: meta,
+	}, nil
+}
+
+// Entry returns the TOC entry with dumpID, or nil if there is none.
+func (d *Dir) Entry(dumpID int) *metadata.TOCEntry {
+	if i, ok := d.byID[dumpID]; ok {
+		return &d.Entries[i]
+	}
+
+	return nil
+}
+
+// Data returns the data of the entry with dumpID, read from the file named
+// in its TOC entry. The directory format records the uncompressed name, so a
+// gzipped file with a .gz suffix is looked for too, while the FILE format
+// names gzipped files with their suffix.
+func (d *Dir) Data(dumpID int) (io.ReadCloser, error) {
+	entry := d.Entry(dumpID)
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownDumpID, dumpID)
+	}
+	if entry.FileName == "" {
+		return nil, fmt.Errorf("%w: dumpId=%d", ErrNoData, dumpID)
+	}
+
+	return openDataFile(d.fsys, entry.FileName)
+}
+
+// openDataFile opens name in fsys, or name.gz, decompressing files with a
+// .gz suffix. Other compressed variants are reported as unsupported.
+func openDataFile(fsys fs.FS, name string) (io.ReadCloser, error) {
+	path, err := dataFilePath(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fsys.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("err opening %s: %w", path, err)
+	}
+	if strings.HasSuffix(path, ".gz") {
+		return gunzip(file, path)
+	}
+
+	return file, nil
+}
+
+// dataFilePath returns the file in fsys holding the data file name: name
+// itself, or name.gz.
+func dataFilePath(fsys fs.FS, name string) (string, error) {
+	_, err := fs.Stat(fsys, name)
+	if err == nil {
+		return name, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("err opening %s: %w", name, err)
+	}
+
+	if _, err := fs.Stat(fsys, name+".gz"); err == nil {
+		return name + ".gz", nil
+	}
+
+	for _, suffix := range []string{".lz4", ".zst"} {
+		if _, statErr := fs.Stat(fsys, name+suffix); statErr == nil {
+			return "", fmt.Errorf("%w: %s%s", ErrUnsupportedCompression, name, suffix)
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrDataFileNotFound, name)
+}
+
+func gunzip(file fs.File, name string) (io.ReadCloser, error) {
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("err decompressing %s: %w", name, err)
+	}
+
+	return &gzipFile{Reader: zr, file: file}, nil
+}
+
+// gzipFile decompresses a data file, closing it along with the decompressor.
+type gzipFile struct {
+	*gzip.Reader
+	file fs.File
+}
+
+func (g *gzipFile) Close() error {
+	return errors.Join(g.Reader.Close(), g.file.Close())
+}
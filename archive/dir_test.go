@@ -0,0 +1,156 @@
+package archive_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func testDir(t *testing.T) fstest.MapFS {
+	t.Helper()
+
+	toc := (&dumptest.Archive{
+		Database: "shop",
+		Format:   5, // DIRECTORY
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop"},
+			{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", FileName: "2.dat", Section: dumptest.SectionData},
+			{Tag: "customers", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", FileName: "3.dat", Section: dumptest.SectionData},
+			{Tag: "items", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", FileName: "4.dat", Section: dumptest.SectionData},
+			{Tag: "lines", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", FileName: "5.dat", Section: dumptest.SectionData},
+		},
+	}).Bytes()
+
+	return fstest.MapFS{
+		"toc.dat":    {Data: toc},
+		"2.dat":      {Data: []byte("1\n2\n")},
+		"3.dat.gz":   {Data: gzipBytes(t, []byte("alice\n"))},
+		"5.dat.lz4":  {Data: []byte("lz4")},
+		"notes.text": {Data: []byte("unrelated")},
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	t.Parallel()
+
+	d, err := archive.OpenDir(testDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Metadata.Format != "DIRECTORY" || len(d.Entries) != 5 {
+		t.Fatalf("unexpected dir: %+v", d.Metadata)
+	}
+
+	testCases := []struct {
+		desc   string
+		dumpID int
+		want   string
+		err    error
+	}{
+		{desc: "plain", dumpID: 2, want: "1\n2\n"},
+		{desc: "gzipped", dumpID: 3, want: "alice\n"},
+		{desc: "missing file", dumpID: 4, err: archive.ErrDataFileNotFound},
+		{desc: "lz4", dumpID: 5, err: archive.ErrUnsupportedCompression},
+		{desc: "no data", dumpID: 1, err: archive.ErrNoData},
+		{desc: "unknown", dumpID: 9, err: archive.ErrUnknownDumpID},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := d.Data(tC.dumpID)
+			if !errors.Is(err, tC.err) {
+				t.Fatalf("expected=%v, got=%v", tC.err, err)
+			}
+			if err != nil {
+				return
+			}
+			defer data.Close()
+
+			got, err := io.ReadAll(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tC.want {
+				t.Errorf("expected=%q, got=%q", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestOpenDirWrongFormat(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{"toc.dat": {Data: testArchive(0).Bytes()}}
+	if _, err := archive.OpenDir(fsys); !errors.Is(err, archive.ErrUnsupportedFormat) {
+		t.Errorf("expected=%v, got=%v", archive.ErrUnsupportedFormat, err)
+	}
+}
+
+func TestOpenFiles(t *testing.T) {
+	t.Parallel()
+
+	archived := (&dumptest.Archive{
+		Database:    "legacy",
+		VMin:        10,
+		Format:      2, // FILE
+		Compression: 5,
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop"},
+			{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", FileName: "2.dat.gz", Section: dumptest.SectionData},
+		},
+	}).Bytes()
+
+	fsys := fstest.MapFS{
+		"legacy.dump": {Data: archived},
+		"2.dat.gz":    {Data: gzipBytes(t, []byte("1\n2\n"))},
+	}
+
+	d, err := archive.OpenFiles(fsys, "legacy.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Metadata.Format != "FILE" || d.Entry(2).Section != "DATA" {
+		t.Fatalf("unexpected archive: %+v", d.Metadata)
+	}
+
+	data, err := d.Data(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+
+	got, err := io.ReadAll(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "1\n2\n" {
+		t.Errorf("expected=%q, got=%q", "1\n2\n", got)
+	}
+
+	if _, err := archive.OpenFiles(fsys, "missing.dump"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected=%v, got=%v", fs.ErrNotExist, err)
+	}
+}
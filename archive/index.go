@@ -0,0 +1,185 @@
+package archive
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var ErrIndexMismatch = errors.New("offset index does not match archive")
+
+// IndexSuffix is appended to an archive's path to name its sidecar index.
+const IndexSuffix = ".offsets.json"
+
+// IndexEntry records where the data block of a TOC entry begins.
+type IndexEntry struct {
+	// Tag is the name of the entry's object.
+	Tag string `json:"tag"`
+	// Desc is the type of the entry.
+	Desc string `json:"desc"`
+	// DumpID identifies the entry.
+	DumpID int `json:"dumpId"`
+	// Offset is the position of the entry's data block.
+	Offset int64 `json:"offset"`
+	// Recorded reports whether the TOC already held this offset.
+	Recorded bool `json:"recorded"`
+}
+
+// Index holds the data block offsets of an archive, reconstructed by walking
+// its data region.
+type Index struct {
+	// Entries are the data blocks found, in archive order.
+	Entries []IndexEntry `json:"entries"`
+	// Missing are dump IDs whose TOC entry has data but no block was found.
+	Missing []int `json:"missing,omitempty"`
+	// Size is the size of the archive the index was built from.
+	Size int64 `json:"size"`
+	// DataStart is the offset of the first data block.
+	DataStart int64 `json:"dataStart"`
+	// Header is the HeaderSum of the archive, when known.
+	Header string `json:"header,omitempty"`
+}
+
+// Walk returns a Reader over the data blocks of a, sharing its header and TOC.
+// Only custom format archives keep their data blocks after the TOC.
+func (a *Archive) Walk() (*Reader, error) {
+	if a.Metadata.Format != "CUSTOM" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, a.Metadata.Format)
+	}
+
+	counter := &metadata.CountingReader{R: io.NewSectionReader(a.r, a.DataStart, a.Size-a.DataStart), N: a.DataStart}
+
+	return newReader(counter, bufio.NewReader(counter), a.Metadata, a.Entries), nil
+}
+
+// ScanOffsets walks every remaining data block of r, recording where each
+// dump ID's data begins. This recovers the offsets pg_dump can't record when
+// writing a custom format archive to a pipe.
+func ScanOffsets(r *Reader) (*Index, error) {
+	idx := &Index{DataStart: r.Offset()}
+	found := make(map[int]bool)
+
+	for {
+		block, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return idx, fmt.Errorf("err scanning data at offset %d: %w", r.Offset(), err)
+		}
+
+		entry := r.Entry(block.DumpID)
+		if entry == nil {
+			return idx, fmt.Errorf("%w: block at offset %d has dumpId=%d", ErrUnknownDumpID, block.Offset, block.DumpID)
+		}
+
+		idx.Entries = append(idx.Entries, IndexEntry{
+			Tag:      entry.Tag,
+			Desc:     entry.Desc,
+			DumpID:   block.DumpID,
+			Offset:   block.Offset,
+			Recorded: entry.DataState == metadata.OffsetPosSet && entry.DataOffset == block.Offset,
+		})
+		found[block.DumpID] = true
+	}
+
+	idx.Size = r.Offset()
+	idx.Missing = missingData(r.Entries, found)
+
+	return idx, nil
+}
+
+// HeaderSum returns the hex SHA-256 of the header and TOC of a, which
+// changes whenever the archive is replaced by another.
+func (a *Archive) HeaderSum() (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(a.r, 0, a.DataStart)); err != nil {
+		return "", fmt.Errorf("err hashing header: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ApplyIndex fills in the data offsets of entries from idx, which must have
+// been built from an archive of the same size and, if idx records one, the
+// same HeaderSum. Entries are left untouched when idx doesn't match.
+func (a *Archive) ApplyIndex(idx *Index) error {
+	if idx.Size != a.Size || idx.DataStart != a.DataStart {
+		return fmt.Errorf("%w: size=%d dataStart=%d, archive size=%d dataStart=%d",
+			ErrIndexMismatch, idx.Size, idx.DataStart, a.Size, a.DataStart)
+	}
+
+	if idx.Header != "" {
+		sum, err := a.HeaderSum()
+		if err != nil {
+			return err
+		}
+		if sum != idx.Header {
+			return fmt.Errorf("%w: header=%s, archive header=%s", ErrIndexMismatch, idx.Header, sum)
+		}
+	}
+
+	for _, ie := range idx.Entries {
+		if a.Entry(ie.DumpID) == nil {
+			return fmt.Errorf("%w: %w: %d", ErrIndexMismatch, ErrUnknownDumpID, ie.DumpID)
+		}
+	}
+
+	for _, ie := range idx.Entries {
+		entry := a.Entry(ie.DumpID)
+		entry.DataState = metadata.OffsetPosSet
+		entry.DataOffset = ie.Offset
+	}
+
+	return nil
+}
+
+// ApplySidecar applies the index stored next to the archive at path, if
+// there is one.
+func (a *Archive) ApplySidecar(path string) error {
+	idx, err := ReadIndex(path + IndexSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return a.ApplyIndex(idx)
+}
+
+// WriteIndex writes idx as JSON to path.
+func WriteIndex(path string, idx *Index) error {
+	out, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return fmt.Errorf("err dumping JSON: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(out, '\n'), 0o600); err != nil {
+		return fmt.Errorf("err writing index: %w", err)
+	}
+
+	return nil
+}
+
+// ReadIndex reads an index written by WriteIndex.
+func ReadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("err reading index: %w", err)
+	}
+
+	idx := &Index{}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("err parsing index: %w", err)
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,215 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+func TestScanOffsets(t *testing.T) {
+	t.Parallel()
+
+	want := testArchive(0).Bytes()
+	expected, err := archive.Open(bytes.NewReader(want), int64(len(want)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	piped := testArchive(0)
+	piped.Piped = true
+	data := piped.Bytes()
+
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Entry(2).DataState != metadata.OffsetPosNotSet {
+		t.Fatalf("expected unset offset, got=%d", a.Entry(2).DataState)
+	}
+
+	walk, err := a.Walk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := archive.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc string
+		r    *archive.Reader
+	}{
+		{desc: "random access", r: walk},
+		{desc: "sequential", r: seq},
+	}
+	for _, tC := range testCases {
+		idx, err := archive.ScanOffsets(tC.r)
+		if err != nil {
+			t.Fatalf("%s: %v", tC.desc, err)
+		}
+
+		if len(idx.Entries) != 2 || len(idx.Missing) != 0 {
+			t.Fatalf("%s: unexpected index: %+v", tC.desc, idx)
+		}
+		if idx.Size != int64(len(data)) || idx.DataStart != a.DataStart {
+			t.Errorf("%s: expected size=%d dataStart=%d, got size=%d dataStart=%d",
+				tC.desc, len(data), a.DataStart, idx.Size, idx.DataStart)
+		}
+		for _, ie := range idx.Entries {
+			if ie.Offset != expected.Entry(ie.DumpID).DataOffset || ie.Recorded {
+				t.Errorf("%s: expected offset=%d, got=%+v", tC.desc, expected.Entry(ie.DumpID).DataOffset, ie)
+			}
+		}
+	}
+}
+
+func TestScanOffsetsRecorded(t *testing.T) {
+	t.Parallel()
+
+	data := testArchive(0).Bytes()
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walk, err := a.Walk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := archive.ScanOffsets(walk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ie := range idx.Entries {
+		if !ie.Recorded {
+			t.Errorf("expected recorded offset, got=%+v", ie)
+		}
+	}
+}
+
+func TestWalkUnsupportedFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []uint8{3, 5} { // TAR, DIRECTORY
+		built := testArchive(0)
+		built.Format = format
+		data := built.Bytes()
+
+		a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Walk(); !errors.Is(err, archive.ErrUnsupportedFormat) {
+			t.Errorf("format=%d: expected=%v, got=%v", format, archive.ErrUnsupportedFormat, err)
+		}
+	}
+}
+
+func TestApplyIndex(t *testing.T) {
+	t.Parallel()
+
+	piped := testArchive(-1)
+	piped.Piped = true
+	data := piped.Bytes()
+
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Data(2); !errors.Is(err, archive.ErrOffsetNotSet) {
+		t.Fatalf("expected=%v, got=%v", archive.ErrOffsetNotSet, err)
+	}
+
+	walk, err := a.Walk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := archive.ScanOffsets(walk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idx.Header, err = a.HeaderSum(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "shop.dump")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.WriteIndex(path+archive.IndexSuffix, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.ApplySidecar(path); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := a.Data(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "alice\nbob\n" {
+		t.Errorf("expected=%q, got=%q", "alice\nbob\n", got)
+	}
+}
+
+func TestApplyIndexMismatch(t *testing.T) {
+	t.Parallel()
+
+	data := testArchive(0).Bytes()
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc string
+		idx  *archive.Index
+	}{
+		{desc: "size", idx: &archive.Index{Size: a.Size + 1, DataStart: a.DataStart}},
+		{desc: "header", idx: &archive.Index{Size: a.Size, DataStart: a.DataStart, Header: "00"}},
+		{
+			desc: "dump id",
+			idx: &archive.Index{
+				Size: a.Size, DataStart: a.DataStart,
+				Entries: []archive.IndexEntry{{DumpID: 2, Offset: 1}, {DumpID: 99, Offset: a.DataStart}},
+			},
+		},
+	}
+	for _, tC := range testCases {
+		if err := a.ApplyIndex(tC.idx); !errors.Is(err, archive.ErrIndexMismatch) {
+			t.Errorf("%s: expected=%v, got=%v", tC.desc, archive.ErrIndexMismatch, err)
+		}
+		if got := a.Entry(2).DataOffset; got == 1 {
+			t.Errorf("%s: expected offsets untouched, got=%d", tC.desc, got)
+		}
+	}
+}
+
+func TestApplySidecarMissing(t *testing.T) {
+	t.Parallel()
+
+	data := testArchive(0).Bytes()
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.ApplySidecar(filepath.Join(t.TempDir(), "absent.dump")); err != nil {
+		t.Errorf("expected=<nil>, got=%v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+// Partial extends metadata.Partial with the data blocks of a possibly
+// truncated custom format archive.
+type Partial struct {
+	*metadata.Partial
+	// Blocks are the data blocks read in full, in archive order.
+	Blocks []IndexEntry `json:"blocks,omitempty"`
+	// MissingData are the dump IDs of entries with data whose block was not
+	// read in full.
+	MissingData []int `json:"missingData,omitempty"`
+	// End is the position just after the last data block read in full, or
+	// where the header and TOC ended if there was none.
+	End int64 `json:"end"`
+}
+
+// ReadPartial reads the archive in r like metadata.ReadPartial, then walks
+// its data blocks until the data runs out, recording which blocks were read
+// in full. An error is only returned when r doesn't hold a dump at all.
+func ReadPartial(r io.Reader) (*Partial, error) {
+	counter := &metadata.CountingReader{R: r}
+	br := bufio.NewReader(counter)
+	offset := func() int64 {
+		return counter.N - int64(br.Buffered())
+	}
+
+	mp, err := metadata.ReadPartial(br)
+	if err != nil {
+		return nil, err
+	}
+	mp.Offset = offset()
+
+	p := &Partial{Partial: mp, End: mp.Offset}
+	if mp.Format != "CUSTOM" {
+		return p, nil
+	}
+	if !mp.Complete() {
+		p.MissingData = missingData(mp.Entries, nil)
+		return p, nil
+	}
+
+	reader := newReader(counter, br, mp.Metadata, mp.Entries)
+	found := make(map[int]bool)
+
+	for {
+		block, err := reader.Next()
+		if err == nil {
+			err = block.skip()
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			p.Err = err.Error()
+			break
+		}
+
+		p.Blocks = append(p.Blocks, IndexEntry{DumpID: block.DumpID, Offset: block.Offset})
+		if entry := reader.Entry(block.DumpID); entry != nil {
+			last := &p.Blocks[len(p.Blocks)-1]
+			last.Tag, last.Desc = entry.Tag, entry.Desc
+			last.Recorded = entry.DataState == metadata.OffsetPosSet && entry.DataOffset == block.Offset
+		}
+		found[block.DumpID] = true
+		p.End = reader.Offset()
+	}
+
+	p.Offset = reader.Offset()
+	p.MissingData = missingData(mp.Entries, found)
+
+	return p, nil
+}
+
+// missingData returns the dump IDs of entries with data that aren't found.
+func missingData(entries []metadata.TOCEntry, found map[int]bool) []int {
+	var missing []int
+	for i := range entries {
+		if entries[i].HasData() && !found[entries[i].DumpID] {
+			missing = append(missing, entries[i].DumpID)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,50 @@
+package archive_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+)
+
+func TestReadPartial(t *testing.T) {
+	t.Parallel()
+
+	data := testArchive(-1).Bytes()
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := a.Entry(3).DataOffset
+
+	testCases := []struct {
+		desc        string
+		size        int64
+		blocks      int
+		missingData []int
+		offset      int64
+		end         int64
+	}{
+		{desc: "complete", size: int64(len(data)), blocks: 2, offset: int64(len(data)), end: int64(len(data))},
+		{desc: "in second block", size: second + 10, blocks: 1, missingData: []int{3}, offset: second + 10, end: second},
+		{desc: "in TOC", size: a.DataStart - 1, missingData: []int{2, 3}, offset: a.DataStart - 1, end: a.DataStart - 1},
+	}
+	for _, tC := range testCases {
+		p, err := archive.ReadPartial(bytes.NewReader(data[:tC.size]))
+		if err != nil {
+			t.Fatalf("%s: %v", tC.desc, err)
+		}
+
+		if len(p.Blocks) != tC.blocks || !reflect.DeepEqual(p.MissingData, tC.missingData) {
+			t.Errorf("%s: expected blocks=%d missing=%v, got blocks=%d missing=%v",
+				tC.desc, tC.blocks, tC.missingData, len(p.Blocks), p.MissingData)
+		}
+		if p.Offset != tC.offset || p.End != tC.end {
+			t.Errorf("%s: expected offset=%d end=%d, got offset=%d end=%d", tC.desc, tC.offset, tC.end, p.Offset, p.End)
+		}
+		if p.Complete() != (tC.size == int64(len(data))) {
+			t.Errorf("%s: unexpected complete=%v, err=%q", tC.desc, p.Complete(), p.Err)
+		}
+	}
+}
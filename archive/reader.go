@@ -0,0 +1,90 @@
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+// Reader reads a custom format archive sequentially, for inputs such as
+// stdin that can't seek.
+type Reader struct {
+	counter *metadata.CountingReader
+	br      *bufio.Reader
+	current *Block
+	byID    map[int]int
+	// Entries is the table of contents.
+	Entries []metadata.TOCEntry
+	// Metadata is the archive header.
+	Metadata metadata.Metadata
+}
+
+// NewReader parses the header and TOC from r, leaving it positioned at the
+// first data block.
+func NewReader(r io.Reader) (*Reader, error) {
+	counter := &metadata.CountingReader{R: r}
+	br := bufio.NewReader(counter)
+
+	meta, entries, err := parse(br)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Format != "CUSTOM" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, meta.Format)
+	}
+
+	return newReader(counter, br, meta, entries), nil
+}
+
+func newReader(counter *metadata.CountingReader, br *bufio.Reader, meta metadata.Metadata, entries []metadata.TOCEntry) *Reader {
+	return &Reader{
+		counter:  counter,
+		br:       br,
+		byID:     indexEntries(entries),
+		Entries:  entries,
+		Metadata: meta,
+	}
+}
+
+// Entry returns the TOC entry with dumpID, or nil if there is none.
+func (r *Reader) Entry(dumpID int) *metadata.TOCEntry {
+	if i, ok := r.byID[dumpID]; ok {
+		return &r.Entries[i]
+	}
+
+	return nil
+}
+
+// Offset returns the position of the next unread byte of the archive.
+func (r *Reader) Offset() int64 {
+	return r.counter.N - int64(r.br.Buffered())
+}
+
+// Next skips whatever remains of the current block and reads the header of
+// the next one, returning io.EOF at the end of the archive.
+func (r *Reader) Next() (*Block, error) {
+	if r.current != nil {
+		if err := r.current.skip(); err != nil {
+			return nil, err
+		}
+		r.current = nil
+	}
+
+	if _, err := r.br.Peek(1); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	block, err := readBlock(&r.Metadata, r.br, r.Offset())
+	if err != nil {
+		return nil, err
+	}
+	r.current = block
+
+	return block, nil
+}
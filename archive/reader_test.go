@@ -0,0 +1,89 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+)
+
+func TestReaderNext(t *testing.T) {
+	t.Parallel()
+
+	built := testArchive(-1)
+	built.Piped = true
+	data := built.Bytes()
+
+	r, err := archive.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skip the first block unread, then read the second.
+	first, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.DumpID != 2 || first.Type != archive.BlockData {
+		t.Errorf("unexpected block: %+v", first)
+	}
+
+	second, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.DumpID != 3 || second.Offset <= first.Offset {
+		t.Errorf("unexpected block: %+v", second)
+	}
+
+	rc, err := second.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, built.Entries[2].Data) {
+		t.Errorf("expected=%q, got=%q", built.Entries[2].Data, got)
+	}
+
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected=%v, got=%v", io.EOF, err)
+	}
+	if r.Offset() != int64(len(data)) {
+		t.Errorf("expected=%d, got=%d", len(data), r.Offset())
+	}
+}
+
+func TestReaderMatchesOffsets(t *testing.T) {
+	t.Parallel()
+
+	data := testArchive(0).Bytes()
+
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := archive.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		block, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if exp := a.Entry(block.DumpID).DataOffset; block.Offset != exp {
+			t.Errorf("dumpId=%d: expected=%d, got=%d", block.DumpID, exp, block.Offset)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var ErrRewriteVerify = errors.New("rewritten archive failed verification")
+
+// File is the destination of Rewrite, written sequentially, patched and
+// read back for verification. *os.File satisfies it.
+type File interface {
+	io.Writer
+	io.WriterAt
+	io.ReaderAt
+}
+
+// Rewrite copies the custom format archive read from src to dst, filling in
+// the TOC data offsets pg_dump leaves unset when writing to a pipe. The input
+// is streamed in a single pass: since an offset field has a fixed size, the
+// TOC is copied as is and its offsets patched once the data region has been
+// walked. The result is then re-parsed and every data offset checked.
+func Rewrite(dst File, src io.Reader) (*Index, error) {
+	counter := &metadata.CountingReader{R: io.TeeReader(src, dst)}
+	br := bufio.NewReader(counter)
+
+	meta, err := metadata.NewMetadata(br)
+	if err != nil {
+		return nil, fmt.Errorf("err reading metadata: %w", err)
+	}
+	if meta.Format != "CUSTOM" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, meta.Format)
+	}
+	if meta.ArchiveVersion() < metadata.VersionWithOffsetFlags {
+		return nil, fmt.Errorf("%w: archive version 1.%d has no offset flags", ErrUnsupportedFormat, meta.VMin)
+	}
+
+	entries, fields, err := readTOCOffsetFields(&meta, br, counter)
+	if err != nil {
+		return nil, fmt.Errorf("err reading TOC: %w", err)
+	}
+
+	idx, err := ScanOffsets(newReader(counter, br, meta, entries))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ie := range idx.Entries {
+		if err := patchOffset(dst, &meta, fields[ie.DumpID], ie.Offset); err != nil {
+			return idx, err
+		}
+	}
+
+	if err := verifyOffsets(dst, idx); err != nil {
+		return idx, err
+	}
+
+	return idx, nil
+}
+
+// readTOCOffsetFields reads the TOC from br, returning the entries and the
+// position of each entry's offset field, keyed by dump ID.
+func readTOCOffsetFields(meta *metadata.Metadata, br *bufio.Reader, counter *metadata.CountingReader) ([]metadata.TOCEntry, map[int]int64, error) {
+	if meta.TOCCount < 0 {
+		return nil, nil, fmt.Errorf("%w: toccount=%d", metadata.ErrInvalidTOC, meta.TOCCount)
+	}
+
+	entries := make([]metadata.TOCEntry, 0, min(meta.TOCCount, metadata.MaxTOCEntriesPreallocated))
+	fields := make(map[int]int64, min(meta.TOCCount, metadata.MaxTOCEntriesPreallocated))
+	fieldSize := 1 + int64(meta.OffSize)
+
+	for i := 0; i < meta.TOCCount; i++ {
+		entry, err := meta.ReadTOCEntry(br)
+		if err != nil {
+			return entries, fields, fmt.Errorf("err reading TOC entry %d: %w", i, err)
+		}
+
+		// The offset field is the last field of a custom format TOC entry.
+		fields[entry.DumpID] = counter.N - int64(br.Buffered()) - fieldSize
+		entries = append(entries, entry)
+	}
+
+	return entries, fields, nil
+}
+
+// patchOffset overwrites the offset field at pos with a set offset.
+func patchOffset(dst io.WriterAt, meta *metadata.Metadata, pos, offset int64) error {
+	if meta.OffSize < 8 && offset >= int64(1)<<(8*meta.OffSize) {
+		return fmt.Errorf("%w: %d does not fit in %d bytes", metadata.ErrInvalidOffset, offset, meta.OffSize)
+	}
+
+	field := make([]byte, 1+int(meta.OffSize))
+	field[0] = metadata.OffsetPosSet
+	for i, val := 1, uint64(offset); i < len(field); i++ {
+		field[i] = byte(val)
+		val >>= 8
+	}
+
+	if _, err := dst.WriteAt(field, pos); err != nil {
+		return fmt.Errorf("err patching offset at %d: %w", pos, err)
+	}
+
+	return nil
+}
+
+// verifyOffsets re-parses the rewritten archive and checks that every entry
+// found in idx now points at its data block.
+func verifyOffsets(r io.ReaderAt, idx *Index) error {
+	a, err := Open(r, idx.Size)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRewriteVerify, err)
+	}
+	if a.DataStart != idx.DataStart {
+		return fmt.Errorf("%w: dataStart=%d, expected=%d", ErrRewriteVerify, a.DataStart, idx.DataStart)
+	}
+
+	for _, ie := range idx.Entries {
+		entry := a.Entry(ie.DumpID)
+		if entry == nil {
+			return fmt.Errorf("%w: %w: %d", ErrRewriteVerify, ErrUnknownDumpID, ie.DumpID)
+		}
+		if _, err := a.EntryBlock(entry); err != nil {
+			return fmt.Errorf("%w: %w", ErrRewriteVerify, err)
+		}
+	}
+
+	return nil
+}
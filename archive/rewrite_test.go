@@ -0,0 +1,77 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+func TestRewrite(t *testing.T) {
+	t.Parallel()
+
+	for _, compression := range []int{0, -1} {
+		expected := testArchive(compression).Bytes()
+
+		piped := testArchive(compression)
+		piped.Piped = true
+
+		out, err := os.Create(filepath.Join(t.TempDir(), "rewritten.dump"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer out.Close()
+
+		idx, err := archive.Rewrite(out, bytes.NewReader(piped.Bytes()))
+		if err != nil {
+			t.Fatalf("compression=%d: %v", compression, err)
+		}
+		if len(idx.Entries) != 2 {
+			t.Errorf("expected 2 entries, got=%d", len(idx.Entries))
+		}
+
+		got, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Errorf("compression=%d: rewritten archive differs from one written with offsets", compression)
+		}
+	}
+}
+
+func TestRewriteUnsupported(t *testing.T) {
+	t.Parallel()
+
+	withFormat := func(format uint8) []byte {
+		built := testArchive(0)
+		built.Format = format
+		return built.Bytes()
+	}
+
+	testCases := []struct {
+		desc     string
+		data     []byte
+		expected error
+	}{
+		{desc: "tar format", data: withFormat(3), expected: archive.ErrUnsupportedFormat},
+		{desc: "directory format", data: withFormat(5), expected: archive.ErrUnsupportedFormat},
+		{desc: "truncated", data: testArchive(0).Bytes()[:200], expected: metadata.ErrNeedMoreData},
+	}
+	for _, tC := range testCases {
+		out, err := os.Create(filepath.Join(t.TempDir(), "rewritten.dump"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = archive.Rewrite(out, bytes.NewReader(tC.data))
+		_ = out.Close()
+		if !errors.Is(err, tC.expected) {
+			t.Errorf("%s: expected=%v, got=%v", tC.desc, tC.expected, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+// salvageWindow is how much of the archive is searched at a time when
+// looking for the next block header after damage.
+const salvageWindow = 1 << 20
+
+// SalvagedBlock is a data block that decoded cleanly.
+type SalvagedBlock struct {
+	// Tag is the name of the block's TOC entry, if it was read.
+	Tag string `json:"tag,omitempty"`
+	// Desc is the type of the block's TOC entry, if it was read.
+	Desc string `json:"desc,omitempty"`
+	// Offset is the position of the block in the archive.
+	Offset int64 `json:"offset"`
+	// Size is the decompressed size of the block's data.
+	Size int64 `json:"size"`
+	// DumpID is the TOC entry the block belongs to.
+	DumpID int `json:"dumpId"`
+	// Type is BlockData or BlockBlobs.
+	Type int `json:"type"`
+}
+
+// Damage is a region of the data area in which no block decoded cleanly.
+type Damage struct {
+	// Err is why decoding failed at Offset.
+	Err string `json:"error"`
+	// Offset is the start of the region.
+	Offset int64 `json:"offset"`
+	// Length is the length of the region.
+	Length int64 `json:"length"`
+}
+
+// SalvageReport is the result of salvaging an archive.
+type SalvageReport struct {
+	// Recovered are the blocks that decoded cleanly, in archive order.
+	Recovered []SalvagedBlock `json:"recovered"`
+	// Damaged are the regions skipped to find the next intact block.
+	Damaged []Damage `json:"damaged"`
+	// Lost are the dump IDs of TOC entries with data whose block could not
+	// be recovered.
+	Lost []int `json:"lost"`
+	// MissingEntries is the number of TOC entries that could not be read,
+	// whose blocks can only be recovered by dump ID.
+	MissingEntries int `json:"missingEntries,omitempty"`
+}
+
+// salvager walks the data area of an archive, resynchronising on block
+// headers after damage.
+type salvager struct {
+	r    io.ReaderAt
+	meta *metadata.Metadata
+	// dumpIDs are the dump IDs blocks may have, or nil to accept any when
+	// the TOC is incomplete.
+	dumpIDs map[int]bool
+	size    int64
+	// spool holds the decompressed contents of the block last tried, so
+	// blocks that decode cleanly are passed on without decoding them again.
+	// It is nil when nothing needs the contents.
+	spool *os.File
+}
+
+// Salvage walks the data area of the custom format archive in r, which is
+// size bytes long, and calls fn with the decompressed contents of every block
+// that decodes cleanly. Whenever a block fails to decode, the archive is
+// searched for the next block header that does, so damage only loses the
+// blocks it touches. The header must be intact; when the TOC is damaged,
+// blocks of entries that were read are still matched to them. Blocks are
+// decompressed once, into a temporary file that fn then reads.
+func Salvage(r io.ReaderAt, size int64, fn func(block SalvagedBlock, data io.Reader) error) (*SalvageReport, error) {
+	counter := &metadata.CountingReader{R: io.NewSectionReader(r, 0, size)}
+	br := bufio.NewReader(counter)
+
+	p, err := metadata.ReadPartial(br)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Missing) > 0 {
+		return nil, fmt.Errorf("err reading metadata: %s", p.Err)
+	}
+	if p.Format != "CUSTOM" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, p.Format)
+	}
+	if algorithm := p.CompressionAlgorithm(); algorithm != "none" && algorithm != "gzip" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCompression, algorithm)
+	}
+
+	s := &salvager{r: r, meta: &p.Metadata, size: size}
+	if p.Complete() {
+		s.dumpIDs = make(map[int]bool, len(p.Entries))
+		for i := range p.Entries {
+			s.dumpIDs[p.Entries[i].DumpID] = true
+		}
+	}
+
+	if fn != nil {
+		if s.spool, err = os.CreateTemp("", "pgdump-salvage-*"); err != nil {
+			return nil, fmt.Errorf("err creating spool file: %w", err)
+		}
+		defer func() {
+			_ = s.spool.Close()
+			_ = os.Remove(s.spool.Name())
+		}()
+	}
+
+	report := &SalvageReport{Recovered: []SalvagedBlock{}, Damaged: []Damage{}, MissingEntries: p.MissingEntries}
+	byID := indexEntries(p.Entries)
+	recovered := make(map[int]bool)
+
+	var damage *Damage
+	pos := counter.N - int64(br.Buffered())
+	for pos < size {
+		block, end, err := s.try(pos)
+		if err != nil {
+			if damage == nil {
+				damage = &Damage{Offset: pos, Err: err.Error()}
+			}
+			if pos, err = s.next(pos + 1); err != nil {
+				return report, err
+			}
+			continue
+		}
+
+		if damage != nil {
+			damage.Length = pos - damage.Offset
+			report.Damaged = append(report.Damaged, *damage)
+			damage = nil
+		}
+		pos = end
+
+		if recovered[block.DumpID] {
+			continue
+		}
+		if i, ok := byID[block.DumpID]; ok {
+			block.Tag, block.Desc = p.Entries[i].Tag, p.Entries[i].Desc
+		}
+		if fn != nil {
+			if err := fn(block, io.NewSectionReader(s.spool, 0, block.Size)); err != nil {
+				return report, err
+			}
+		}
+		report.Recovered = append(report.Recovered, block)
+		recovered[block.DumpID] = true
+	}
+
+	if damage != nil {
+		damage.Length = size - damage.Offset
+		report.Damaged = append(report.Damaged, *damage)
+	}
+	report.Lost = missingData(p.Entries, recovered)
+
+	return report, nil
+}
+
+// open reads the header of the block at pos.
+func (s *salvager) open(pos int64) (*Block, *metadata.CountingReader, *bufio.Reader, error) {
+	counter := &metadata.CountingReader{R: io.NewSectionReader(s.r, pos, s.size-pos)}
+	br := bufio.NewReader(counter)
+
+	block, err := readBlock(s.meta, br, pos)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if s.dumpIDs != nil && !s.dumpIDs[block.DumpID] {
+		return nil, nil, nil, fmt.Errorf("%w: %d", ErrUnknownDumpID, block.DumpID)
+	}
+
+	return block, counter, br, nil
+}
+
+// try decodes the whole block at pos, into the spool file when there is one,
+// returning it and where it ends.
+func (s *salvager) try(pos int64) (SalvagedBlock, int64, error) {
+	block, counter, br, err := s.open(pos)
+	if err != nil {
+		return SalvagedBlock{}, 0, err
+	}
+
+	var w io.Writer = io.Discard
+	if s.spool != nil {
+		if err := s.spool.Truncate(0); err != nil {
+			return SalvagedBlock{}, 0, fmt.Errorf("err truncating spool file: %w", err)
+		}
+		if _, err := s.spool.Seek(0, io.SeekStart); err != nil {
+			return SalvagedBlock{}, 0, fmt.Errorf("err rewinding spool file: %w", err)
+		}
+		w = s.spool
+	}
+
+	size, err := s.decode(block, w)
+	if err != nil {
+		return SalvagedBlock{}, 0, err
+	}
+
+	salvaged := SalvagedBlock{Offset: pos, Size: size, DumpID: block.DumpID, Type: block.Type}
+
+	return salvaged, pos + counter.N - int64(br.Buffered()), nil
+}
+
+// decode writes the decompressed contents of every chunk of the block to w,
+// failing unless each stream ends exactly at its terminating chunk.
+func (s *salvager) decode(block *Block, w io.Writer) (int64, error) {
+	if block.Type == BlockData {
+		data, err := block.Data()
+		if err != nil {
+			return 0, err
+		}
+		return copyStream(w, block, data)
+	}
+
+	var total int64
+	for {
+		_, data, err := block.NextBlob()
+		if errors.Is(err, io.EOF) {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+
+		n, err := copyStream(w, block, data)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+}
+
+// copyStream copies data to w and checks nothing is left of the chunks.
+func copyStream(w io.Writer, block *Block, data io.ReadCloser) (int64, error) {
+	n, err := io.Copy(w, data)
+	_ = data.Close()
+	if err != nil {
+		return n, err
+	}
+
+	trailing, err := io.Copy(io.Discard, block.chunks)
+	if err != nil {
+		return n, err
+	}
+	if trailing > 0 {
+		return n, fmt.Errorf("%w: %d bytes after end of compressed stream", ErrInvalidChunk, trailing)
+	}
+
+	return n, nil
+}
+
+// next returns the position of the next plausible block header at or after
+// pos, or the archive size if there is none.
+func (s *salvager) next(pos int64) (int64, error) {
+	headerSize := 2 + int64(s.meta.IntSize)
+	buf := make([]byte, salvageWindow)
+
+	for pos+headerSize <= s.size {
+		n, err := s.r.ReadAt(buf[:min(int64(len(buf)), s.size-pos)], pos)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("err reading at offset %d: %w", pos, err)
+		}
+
+		for i := 0; int64(i)+headerSize <= int64(n); i++ {
+			if s.plausible(buf[i : int64(i)+headerSize]) {
+				return pos + int64(i), nil
+			}
+		}
+
+		// Windows overlap so headers straddling them aren't missed.
+		pos += max(int64(n)-headerSize+1, 1)
+	}
+
+	return s.size, nil
+}
+
+// plausible reports whether header could start a data block: a block type,
+// then a positive dump ID that belongs to the TOC.
+func (s *salvager) plausible(header []byte) bool {
+	if header[0] != BlockData && header[0] != BlockBlobs {
+		return false
+	}
+	if header[1] != 0 {
+		return false
+	}
+
+	var dumpID uint64
+	for i := len(header) - 1; i >= 2; i-- {
+		dumpID = (dumpID << 8) | uint64(header[i])
+	}
+	if dumpID == 0 || dumpID > math.MaxInt32 {
+		return false
+	}
+
+	return s.dumpIDs == nil || s.dumpIDs[int(dumpID)]
+}
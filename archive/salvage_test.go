@@ -0,0 +1,123 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+)
+
+func salvageArchive(compression int) *dumptest.Archive {
+	built := testArchive(compression)
+	built.Entries = append(built.Entries, dumptest.Entry{
+		Tag: "items", Desc: "TABLE DATA", Namespace: "public", Owner: "shop",
+		CopyStmt: "COPY public.items (sku) FROM stdin;\n",
+		Data:     []byte(strings.Repeat("sku-1\nsku-2\n", 10)),
+		DumpID:   7,
+	})
+
+	return built
+}
+
+func salvage(t *testing.T, data []byte) (*archive.SalvageReport, map[int]string) {
+	t.Helper()
+
+	got := make(map[int]string)
+	report, err := archive.Salvage(bytes.NewReader(data), int64(len(data)), func(block archive.SalvagedBlock, r io.Reader) error {
+		contents, err := io.ReadAll(r)
+		got[block.DumpID] = string(contents)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return report, got
+}
+
+func TestSalvage(t *testing.T) {
+	t.Parallel()
+
+	for _, compression := range []int{0, -1} {
+		built := salvageArchive(compression)
+		data := built.Bytes()
+		a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := a.Entry(2).DataOffset
+		second := a.Entry(3).DataOffset
+
+		intact, got := salvage(t, data)
+		if len(intact.Recovered) != 3 || len(intact.Damaged) != 0 || len(intact.Lost) != 0 {
+			t.Fatalf("compression=%d: unexpected report of intact archive: %+v", compression, intact)
+		}
+		if got[7] != string(built.Entries[4].Data) {
+			t.Errorf("compression=%d: expected=%q, got=%q", compression, built.Entries[4].Data, got[7])
+		}
+
+		// Break the chunk structure of the first block.
+		damaged := bytes.Clone(data)
+		copy(damaged[first+5+1:], dumptest.Int(1<<30))
+
+		report, got := salvage(t, damaged)
+		if !reflect.DeepEqual(report.Lost, []int{2}) {
+			t.Errorf("compression=%d: expected lost=[2], got=%v", compression, report.Lost)
+		}
+		if len(report.Damaged) != 1 || report.Damaged[0].Offset != first || report.Damaged[0].Length != second-first {
+			t.Errorf("compression=%d: expected damage at %d of %d bytes, got=%+v", compression, first, second-first, report.Damaged)
+		}
+		if len(report.Recovered) != 2 || report.Recovered[0].Tag != "customers" || report.Recovered[1].DumpID != 7 {
+			t.Errorf("compression=%d: unexpected recovered blocks: %+v", compression, report.Recovered)
+		}
+		if got[3] != "alice\nbob\n" || got[7] != string(built.Entries[4].Data) {
+			t.Errorf("compression=%d: unexpected salvaged data: %q", compression, got)
+		}
+	}
+}
+
+func TestSalvageCorruptStream(t *testing.T) {
+	t.Parallel()
+
+	data := salvageArchive(-1).Bytes()
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the deflate stream of the second block, keeping its chunks
+	// intact, and cut the last block short.
+	damaged := bytes.Clone(data[:len(data)-3])
+	damaged[a.Entry(3).DataOffset+1+5+5+4] ^= 0xff
+
+	report, got := salvage(t, damaged)
+	if !reflect.DeepEqual(report.Lost, []int{3, 7}) {
+		t.Errorf("expected lost=[3 7], got=%v", report.Lost)
+	}
+	if len(report.Damaged) != 1 || report.Damaged[0].Offset != a.Entry(3).DataOffset {
+		t.Errorf("expected damage from %d to the end, got=%+v", a.Entry(3).DataOffset, report.Damaged)
+	}
+	if len(got) != 1 || got[2] != strings.Repeat("1\n2\n3\n", 20) {
+		t.Errorf("unexpected salvaged data: %q", got)
+	}
+}
+
+func TestSalvageUnsupported(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []uint8{3, 5} { // TAR, DIRECTORY
+		built := testArchive(0)
+		built.Format = format
+		data := built.Bytes()
+
+		_, err := archive.Salvage(bytes.NewReader(data), int64(len(data)), nil)
+		if !errors.Is(err, archive.ErrUnsupportedFormat) {
+			t.Errorf("format=%d: expected=%v, got=%v", format, archive.ErrUnsupportedFormat, err)
+		}
+	}
+}
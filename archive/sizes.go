@@ -0,0 +1,184 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+// TableSize is how much space the data of a table takes in an archive, and
+// how much it takes once decompressed.
+type TableSize struct {
+	// Namespace is the schema of the table.
+	Namespace string `json:"namespace,omitempty"`
+	// Tag is the name of the table.
+	Tag string `json:"tag"`
+	// DumpID is the TOC entry of the table's data.
+	DumpID int `json:"dumpId"`
+	// Offset is the position of the data block in a custom format archive.
+	Offset int64 `json:"offset,omitempty"`
+	// File is the data file holding the table's data in a dump that keeps
+	// it in separate files.
+	File string `json:"file,omitempty"`
+	// Compressed is the size of the data block in the archive, including
+	// its header and chunk lengths, or the size of the data file.
+	Compressed int64 `json:"compressed"`
+	// Decompressed is the size of the table's COPY data.
+	Decompressed int64 `json:"decompressed"`
+	// Ratio is Decompressed divided by Compressed.
+	Ratio float64 `json:"ratio"`
+}
+
+// SizeReport lists the size of every table's data, largest first.
+type SizeReport struct {
+	// Tables are the TABLE DATA entries found, by compressed size, largest
+	// first.
+	Tables []TableSize `json:"tables"`
+	// Compressed is the total compressed size of the tables.
+	Compressed int64 `json:"compressed"`
+	// Decompressed is the total decompressed size of the tables.
+	Decompressed int64 `json:"decompressed"`
+}
+
+// Top truncates the report to its n largest tables, keeping the totals of
+// them all. A non-positive n keeps every table.
+func (s *SizeReport) Top(n int) {
+	if n > 0 && n < len(s.Tables) {
+		s.Tables = s.Tables[:n]
+	}
+}
+
+// Sizes reads every TABLE DATA block of the archive, decompressing it to
+// measure its size. Other blocks, such as blobs, are skipped.
+func Sizes(r *Reader) (*SizeReport, error) {
+	report := &SizeReport{Tables: []TableSize{}}
+
+	for {
+		block, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+
+		entry := r.Entry(block.DumpID)
+		if entry == nil || entry.Desc != "TABLE DATA" || block.Type != BlockData {
+			continue
+		}
+
+		decompressed, err := decompressedSize(block)
+		if err != nil {
+			return report, err
+		}
+
+		size := TableSize{
+			Namespace:    entry.Namespace,
+			Tag:          entry.Tag,
+			DumpID:       entry.DumpID,
+			Offset:       block.Offset,
+			Compressed:   r.Offset() - block.Offset,
+			Decompressed: decompressed,
+		}
+		if size.Compressed > 0 {
+			size.Ratio = float64(size.Decompressed) / float64(size.Compressed)
+		}
+
+		report.add(size)
+	}
+	report.sort()
+
+	return report, nil
+}
+
+// DirSizes measures the data file of every TABLE DATA entry of d, such as a
+// directory or tar format dump, decompressing it to measure its size.
+func DirSizes(d *Dir) (*SizeReport, error) {
+	report := &SizeReport{Tables: []TableSize{}}
+
+	for i := range d.Entries {
+		entry := &d.Entries[i]
+		if entry.Desc != "TABLE DATA" || entry.FileName == "" {
+			continue
+		}
+
+		size, err := fileSize(d, entry)
+		if err != nil {
+			return report, err
+		}
+		report.add(size)
+	}
+	report.sort()
+
+	return report, nil
+}
+
+// fileSize measures the data file of entry.
+func fileSize(d *Dir, entry *metadata.TOCEntry) (TableSize, error) {
+	size := TableSize{Namespace: entry.Namespace, Tag: entry.Tag, DumpID: entry.DumpID}
+
+	path, err := dataFilePath(d.fsys, entry.FileName)
+	if err != nil {
+		return size, err
+	}
+	info, err := fs.Stat(d.fsys, path)
+	if err != nil {
+		return size, fmt.Errorf("err opening %s: %w", path, err)
+	}
+	size.File, size.Compressed = path, info.Size()
+
+	data, err := d.Data(entry.DumpID)
+	if err != nil {
+		return size, err
+	}
+	defer data.Close()
+
+	if size.Decompressed, err = io.Copy(io.Discard, data); err != nil {
+		return size, fmt.Errorf("err decompressing %s: %w", path, err)
+	}
+	if size.Compressed > 0 {
+		size.Ratio = float64(size.Decompressed) / float64(size.Compressed)
+	}
+
+	return size, nil
+}
+
+func (s *SizeReport) add(size TableSize) {
+	s.Tables = append(s.Tables, size)
+	s.Compressed += size.Compressed
+	s.Decompressed += size.Decompressed
+}
+
+// sort orders the tables by compressed size, largest first.
+func (s *SizeReport) sort() {
+	sort.SliceStable(s.Tables, func(i, j int) bool {
+		return s.Tables[i].Compressed > s.Tables[j].Compressed
+	})
+}
+
+// decompressedSize reads the block's data to its end, leaving the block
+// fully consumed.
+func decompressedSize(block *Block) (int64, error) {
+	data, err := block.Data()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(io.Discard, data)
+	_ = data.Close()
+	if err != nil {
+		return n, err
+	}
+
+	// Read through to the terminating chunk, past anything following the end
+	// of the compressed stream.
+	if _, err := io.Copy(io.Discard, block.chunks); err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
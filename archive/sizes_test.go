@@ -0,0 +1,105 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+)
+
+func TestSizes(t *testing.T) {
+	t.Parallel()
+
+	for _, compression := range []int{0, 6} {
+		built := testArchive(compression)
+		blocks := built.DataBlocks()
+
+		r, err := archive.NewReader(bytes.NewReader(built.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := archive.Sizes(r)
+		if err != nil {
+			t.Fatalf("compression=%d: %v", compression, err)
+		}
+		if len(report.Tables) != 2 {
+			t.Fatalf("compression=%d: expected=%d, got=%d", compression, 2, len(report.Tables))
+		}
+
+		// orders has more data, so it comes first.
+		orders, customers := report.Tables[0], report.Tables[1]
+		if orders.Tag != "orders" || customers.Tag != "customers" {
+			t.Fatalf("compression=%d: unexpected order: %+v", compression, report.Tables)
+		}
+		if orders.Compressed != int64(len(blocks[1])) || customers.Compressed != int64(len(blocks[2])) {
+			t.Errorf("compression=%d: expected=%d/%d, got=%d/%d", compression,
+				len(blocks[1]), len(blocks[2]), orders.Compressed, customers.Compressed)
+		}
+		if orders.Decompressed != int64(len(built.Entries[1].Data)) || customers.Decompressed != int64(len(built.Entries[2].Data)) {
+			t.Errorf("compression=%d: unexpected decompressed sizes: %+v", compression, report.Tables)
+		}
+		if report.Decompressed != orders.Decompressed+customers.Decompressed {
+			t.Errorf("compression=%d: expected=%d, got=%d", compression, orders.Decompressed+customers.Decompressed, report.Decompressed)
+		}
+
+		wantRatio := float64(orders.Decompressed) / float64(orders.Compressed)
+		if orders.Ratio != wantRatio {
+			t.Errorf("compression=%d: expected=%v, got=%v", compression, wantRatio, orders.Ratio)
+		}
+
+		report.Top(1)
+		if len(report.Tables) != 1 || report.Compressed != orders.Compressed+customers.Compressed {
+			t.Errorf("compression=%d: unexpected top report: %+v", compression, report)
+		}
+	}
+}
+
+func TestDirSizes(t *testing.T) {
+	t.Parallel()
+
+	fsys := testDir(t)
+	delete(fsys, "4.dat")
+	delete(fsys, "5.dat.lz4")
+	fsys["4.dat"] = &fstest.MapFile{Data: []byte(strings.Repeat("widget\n", 10))}
+	fsys["5.dat"] = &fstest.MapFile{}
+
+	report, err := archive.DirSizes(mustOpenDir(t, fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []archive.TableSize{
+		{Namespace: "public", Tag: "items", DumpID: 4, File: "4.dat", Compressed: 70, Decompressed: 70, Ratio: 1},
+		{Namespace: "public", Tag: "customers", DumpID: 3, File: "3.dat.gz", Compressed: int64(len(fsys["3.dat.gz"].Data)), Decompressed: 6},
+		{Namespace: "public", Tag: "orders", DumpID: 2, File: "2.dat", Compressed: 4, Decompressed: 4, Ratio: 1},
+		{Namespace: "public", Tag: "lines", DumpID: 5, File: "5.dat"},
+	}
+	want[1].Ratio = 6 / float64(want[1].Compressed)
+	if !reflect.DeepEqual(want, report.Tables) {
+		t.Errorf("expected=%+v, got=%+v", want, report.Tables)
+	}
+	if report.Decompressed != 80 {
+		t.Errorf("expected=%d, got=%d", 80, report.Decompressed)
+	}
+
+	if _, err := archive.DirSizes(mustOpenDir(t, testDir(t))); !errors.Is(err, archive.ErrDataFileNotFound) {
+		t.Errorf("expected=%v, got=%v", archive.ErrDataFileNotFound, err)
+	}
+}
+
+func mustOpenDir(t *testing.T, fsys fs.FS) *archive.Dir {
+	t.Helper()
+
+	d, err := archive.OpenDir(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
@@ -0,0 +1,220 @@
+package archive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+// Problem is an integrity issue found while verifying an archive.
+type Problem struct {
+	// Tag is the name of the TOC entry concerned, if any.
+	Tag string `json:"tag,omitempty"`
+	// Desc is the type of the TOC entry concerned, if any.
+	Desc string `json:"desc,omitempty"`
+	// Message describes the problem.
+	Message string `json:"message"`
+	// Offset is the position in the archive where the problem was found.
+	Offset int64 `json:"offset"`
+	// DumpID is the TOC entry concerned, if any.
+	DumpID int `json:"dumpId,omitempty"`
+}
+
+// Report is the result of verifying an archive.
+type Report struct {
+	// Problems are the integrity issues found, in archive order.
+	Problems []Problem `json:"problems"`
+	// Entries is the number of TOC entries read.
+	Entries int `json:"entries"`
+	// Blocks is the number of data blocks read.
+	Blocks int `json:"blocks"`
+	// Undecoded is the number of blocks whose compression isn't supported,
+	// for which only the chunk structure was checked.
+	Undecoded int `json:"undecoded,omitempty"`
+	// Size is the number of bytes read.
+	Size int64 `json:"size"`
+}
+
+// OK reports whether no problems were found.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Report) add(entry *metadata.TOCEntry, offset int64, format string, args ...any) {
+	problem := Problem{Message: fmt.Sprintf(format, args...), Offset: offset}
+	if entry != nil {
+		problem.Tag = entry.Tag
+		problem.Desc = entry.Desc
+		problem.DumpID = entry.DumpID
+	}
+
+	r.Problems = append(r.Problems, problem)
+}
+
+// Verify reads the custom format archive in r to its end, checking the
+// header, every TOC entry and every data block: that each block belongs to a
+// TOC entry with data and sits at its recorded offset, that compressed data
+// decodes cleanly to the end of its stream, and that the archive does not end
+// mid-block. Integrity issues are collected in the report; an error is only
+// returned when the archive can't be verified at all.
+func Verify(r io.Reader) (*Report, error) {
+	report := &Report{Problems: []Problem{}}
+	counter := &metadata.CountingReader{R: r}
+	br := bufio.NewReader(counter)
+	offset := func() int64 {
+		return counter.N - int64(br.Buffered())
+	}
+
+	meta, entries, err := parse(br)
+	report.Entries = len(entries)
+	report.Size = offset()
+	if err != nil {
+		report.add(nil, offset(), "%v", err)
+		return report, nil
+	}
+	if meta.Format != "CUSTOM" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, meta.Format)
+	}
+
+	reader := newReader(counter, br, meta, entries)
+	seen := make(map[int]bool, len(entries))
+
+	for {
+		block, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			report.add(nil, reader.Offset(), "%v", describeReadErr(err))
+			break
+		}
+		report.Blocks++
+
+		entry := reader.Entry(block.DumpID)
+		verifyBlockEntry(report, entry, block, seen)
+		seen[block.DumpID] = true
+
+		if err := verifyBlockData(report, entry, block); err != nil {
+			report.add(entry, reader.Offset(), "%v", describeReadErr(err))
+			break
+		}
+	}
+
+	report.Size = reader.Offset()
+
+	for i := range entries {
+		entry := &entries[i]
+		if !entry.HasData() || seen[entry.DumpID] {
+			continue
+		}
+		offset := report.Size
+		if entry.DataState == metadata.OffsetPosSet {
+			offset = entry.DataOffset
+		}
+		report.add(entry, offset, "no data block found")
+	}
+
+	return report, nil
+}
+
+// verifyBlockEntry checks the block against the TOC entry it belongs to.
+func verifyBlockEntry(report *Report, entry *metadata.TOCEntry, block *Block, seen map[int]bool) {
+	switch {
+	case entry == nil:
+		report.add(nil, block.Offset, "data block for unknown dumpId=%d", block.DumpID)
+		return
+	case seen[block.DumpID]:
+		report.add(entry, block.Offset, "duplicate data block")
+	case !entry.HasData():
+		report.add(entry, block.Offset, "data block for TOC entry without data")
+	}
+
+	if entry.DataState == metadata.OffsetPosSet && entry.DataOffset != block.Offset {
+		report.add(entry, block.Offset, "TOC records data offset %d", entry.DataOffset)
+	}
+}
+
+// verifyBlockData decodes the contents of the block. Problems with the
+// contents are added to the report; a returned error means the archive can't
+// be read any further.
+func verifyBlockData(report *Report, entry *metadata.TOCEntry, block *Block) error {
+	if block.Type == BlockBlobs {
+		for {
+			oid, data, err := block.NextBlob()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if errors.Is(err, ErrUnsupportedCompression) {
+				report.Undecoded++
+				return nil
+			}
+			if err != nil {
+				if isTruncation(err) {
+					return err
+				}
+				report.add(entry, block.Offset, "blob %d: %v", oid, err)
+				continue
+			}
+			if err := verifyStream(report, entry, block, data); err != nil {
+				return fmt.Errorf("blob %d: %w", oid, err)
+			}
+		}
+	}
+
+	data, err := block.Data()
+	if errors.Is(err, ErrUnsupportedCompression) {
+		report.Undecoded++
+		return nil
+	}
+	if err != nil {
+		if isTruncation(err) {
+			return err
+		}
+		report.add(entry, block.Offset, "%v", err)
+		return nil
+	}
+
+	return verifyStream(report, entry, block, data)
+}
+
+// verifyStream reads data to its end and checks that no chunk data follows
+// the end of a compressed stream.
+func verifyStream(report *Report, entry *metadata.TOCEntry, block *Block, data io.ReadCloser) error {
+	_, err := io.Copy(io.Discard, data)
+	_ = data.Close()
+	if err != nil {
+		if isTruncation(err) {
+			return err
+		}
+		report.add(entry, block.Offset, "err decoding data: %v", err)
+		return nil
+	}
+
+	trailing, err := io.Copy(io.Discard, block.chunks)
+	if err != nil {
+		return err
+	}
+	if trailing > 0 {
+		report.add(entry, block.Offset, "%d bytes after end of compressed stream", trailing)
+	}
+
+	return nil
+}
+
+// isTruncation reports whether err means the archive ended early or its
+// chunk structure is broken, so that no further blocks can be located.
+func isTruncation(err error) bool {
+	return errors.Is(err, metadata.ErrNeedMoreData) || errors.Is(err, ErrInvalidChunk)
+}
+
+// describeReadErr explains an error that stopped the walk of the data region.
+func describeReadErr(err error) error {
+	if errors.Is(err, metadata.ErrNeedMoreData) {
+		return fmt.Errorf("archive ends mid-block: %w", err)
+	}
+
+	return err
+}
@@ -0,0 +1,127 @@
+package archive_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	built := testArchive(-1)
+	data := built.Bytes()
+	blocks := built.DataBlocks()
+	a, err := archive.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := a.Entry(2).DataOffset, a.Entry(3).DataOffset
+
+	corrupt := bytes.Clone(data)
+	// The first chunk payload starts after the block header and chunk length;
+	// flip a byte of the deflate stream past its zlib header.
+	corrupt[first+1+5+5+4] ^= 0xff
+
+	unknown := bytes.Clone(data)
+	unknown[second+1+1] = 99
+
+	testCases := []struct {
+		desc     string
+		data     []byte
+		messages []string
+		offsets  []int64
+	}{
+		{desc: "intact", data: data},
+		{desc: "truncated mid-block", data: data[:second+3], messages: []string{"ends mid-block", "no data block found"}, offsets: []int64{second + 3, second}},
+		{desc: "truncated between blocks", data: data[:first+int64(len(blocks[1]))], messages: []string{"no data block found"}, offsets: []int64{second}},
+		{desc: "corrupt stream", data: corrupt, messages: []string{"err decoding data"}, offsets: []int64{first}},
+		{desc: "unknown dump id", data: unknown, messages: []string{"unknown dumpId=99", "no data block found"}, offsets: []int64{second, second}},
+		{desc: "truncated TOC", data: data[:first-10], messages: []string{"err reading TOC"}},
+	}
+	for _, tC := range testCases {
+		report, err := archive.Verify(bytes.NewReader(tC.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tC.desc, err)
+		}
+
+		if len(report.Problems) != len(tC.messages) {
+			t.Fatalf("%s: expected %d problems, got=%+v", tC.desc, len(tC.messages), report.Problems)
+		}
+		if report.OK() != (len(tC.messages) == 0) {
+			t.Errorf("%s: expected OK=%v", tC.desc, len(tC.messages) == 0)
+		}
+		for i, msg := range tC.messages {
+			if !strings.Contains(report.Problems[i].Message, msg) {
+				t.Errorf("%s: expected=%q, got=%q", tC.desc, msg, report.Problems[i].Message)
+			}
+			if tC.offsets != nil && report.Problems[i].Offset != tC.offsets[i] {
+				t.Errorf("%s: expected offset=%d, got=%d", tC.desc, tC.offsets[i], report.Problems[i].Offset)
+			}
+		}
+	}
+}
+
+func TestVerifyEntry(t *testing.T) {
+	t.Parallel()
+
+	data := testArchive(0).Bytes()
+	report, err := archive.Verify(bytes.NewReader(data[:len(data)-2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Problems) != 1 {
+		t.Fatalf("expected 1 problem, got=%+v", report.Problems)
+	}
+	if p := report.Problems[0]; p.DumpID != 3 || p.Tag != "customers" || p.Desc != "TABLE DATA" {
+		t.Errorf("unexpected problem: %+v", p)
+	}
+	if report.Blocks != 2 || report.Entries != 4 {
+		t.Errorf("expected blocks=2 entries=4, got blocks=%d entries=%d", report.Blocks, report.Entries)
+	}
+}
+
+func TestVerifyUnsupportedCompression(t *testing.T) {
+	t.Parallel()
+
+	built := testArchive(0)
+	built.VMin = 16
+	built.Algorithm = "zstd"
+	// The blob block sits between the data blocks, so the walk has to skip
+	// it to reach the last one.
+	built.Entries = slices.Insert(built.Entries, 2, dumptest.Entry{
+		Tag: "BLOBS", Desc: "BLOBS", Owner: "shop",
+		Blobs: []dumptest.Blob{{OID: 16401, Data: []byte("first")}, {OID: 16402, Data: []byte("second")}},
+	})
+
+	report, err := archive.Verify(bytes.NewReader(built.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() {
+		t.Errorf("expected no problems, got=%+v", report.Problems)
+	}
+	if report.Blocks != 3 || report.Undecoded != 3 {
+		t.Errorf("expected blocks=3 undecoded=3, got blocks=%d undecoded=%d", report.Blocks, report.Undecoded)
+	}
+}
+
+func TestVerifyUnsupportedFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []uint8{3, 5} { // TAR, DIRECTORY
+		built := testArchive(0)
+		built.Format = format
+
+		if _, err := archive.Verify(bytes.NewReader(built.Bytes())); !errors.Is(err, archive.ErrUnsupportedFormat) {
+			t.Errorf("format=%d: expected=%v, got=%v", format, archive.ErrUnsupportedFormat, err)
+		}
+	}
+}
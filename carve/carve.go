@@ -0,0 +1,151 @@
+// Package carve finds pg_dump archives embedded in arbitrary data, such as
+// disk images or concatenated backup blobs.
+package carve
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var ErrImplausible = errors.New("implausible archive header")
+
+// magic starts every pg_dump archive header.
+var magic = []byte("PGDMP")
+
+// window is how much of the input is searched for the magic at a time.
+const window = 1 << 20
+
+// Limits applied to candidate headers.
+const (
+	maxMinorVersion = 16
+	maxTOCCount     = 1 << 24
+	earliestYear    = 2000
+	// clockSkew allows for dumps created on hosts whose clock runs ahead.
+	clockSkew = 48 * time.Hour
+)
+
+// Hit is an archive found embedded in the input.
+type Hit struct {
+	// Metadata is the archive header.
+	Metadata metadata.Metadata `json:"metadata"`
+	// Offset is the position of the archive's magic in the input.
+	Offset int64 `json:"offset"`
+	// Length is the estimated length of the archive: up to the end of its
+	// last intact data block for the custom format, and of its TOC for
+	// formats that keep data elsewhere.
+	Length int64 `json:"length"`
+	// Complete reports whether the TOC and, for the custom format, the data
+	// block of every TOC entry with data were found.
+	Complete bool `json:"complete"`
+}
+
+// Result is the outcome of a scan.
+type Result struct {
+	// Hits are the plausible archives found, in input order.
+	Hits []Hit `json:"hits"`
+	// Rejected is the number of magic matches whose header was implausible.
+	Rejected int `json:"rejected"`
+}
+
+// Scan searches the size bytes of r for archive headers. Each match of the
+// magic is parsed and kept only if its header is plausible for an archive
+// created before now; its length is then estimated by walking its TOC and
+// data blocks.
+func Scan(r io.ReaderAt, size int64, now time.Time) (*Result, error) {
+	res := &Result{Hits: []Hit{}}
+	buf := make([]byte, window)
+
+	for pos := int64(0); pos+int64(len(magic)) <= size; {
+		n, err := r.ReadAt(buf[:min(int64(len(buf)), size-pos)], pos)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return res, fmt.Errorf("err reading at offset %d: %w", pos, err)
+		}
+
+		for i := 0; ; i++ {
+			found := bytes.Index(buf[i:n], magic)
+			if found < 0 {
+				break
+			}
+			i += found
+
+			hit, err := Probe(r, size, pos+int64(i), now)
+			if err != nil {
+				res.Rejected++
+				continue
+			}
+			res.Hits = append(res.Hits, hit)
+		}
+
+		// Windows overlap so a magic straddling them isn't missed.
+		pos += max(int64(n)-int64(len(magic))+1, 1)
+	}
+
+	return res, nil
+}
+
+// Probe parses the archive at offset in r, returning it as a hit if its
+// header is plausible.
+func Probe(r io.ReaderAt, size, offset int64, now time.Time) (Hit, error) {
+	p, err := archive.ReadPartial(io.NewSectionReader(r, offset, size-offset))
+	if err != nil {
+		return Hit{}, err
+	}
+	if len(p.Missing) > 0 {
+		return Hit{}, fmt.Errorf("%w: %s", ErrImplausible, p.Err)
+	}
+	if err := Plausible(&p.Metadata, now); err != nil {
+		return Hit{}, err
+	}
+
+	return Hit{
+		Metadata: p.Metadata,
+		Offset:   offset,
+		Length:   p.End,
+		Complete: p.EntriesRead == p.TOCCount && len(p.MissingData) == 0,
+	}, nil
+}
+
+// Plausible checks that the fields of a parsed header are within the ranges
+// pg_dump writes, so that stray matches of the magic are rejected. Archives
+// before 1.4 record no creation time, so only their other fields are checked.
+func Plausible(m *metadata.Metadata, now time.Time) error {
+	switch {
+	case m.VMain != 1 || m.VMin > maxMinorVersion:
+		return fmt.Errorf("%w: version %d.%d", ErrImplausible, m.VMain, m.VMin)
+	case m.IntSize != 4 && m.IntSize != 8:
+		return fmt.Errorf("%w: intsize=%d", ErrImplausible, m.IntSize)
+	case m.OffSize != 4 && m.OffSize != 8:
+		return fmt.Errorf("%w: offsize=%d", ErrImplausible, m.OffSize)
+	case m.Format == "UNKNOWN" || m.Format == "NULL":
+		return fmt.Errorf("%w: format=%s", ErrImplausible, m.Format)
+	case m.TOCCount < 0 || m.TOCCount > maxTOCCount:
+		return fmt.Errorf("%w: toccount=%d", ErrImplausible, m.TOCCount)
+	case !m.HasCreatedAt():
+		return nil
+	case m.TimeSec < 0 || m.TimeSec > 61 || m.TimeMin < 0 || m.TimeMin > 59 || m.TimeHour < 0 || m.TimeHour > 23,
+		m.TimeDay < 1 || m.TimeDay > 31 || m.TimeMonth < 0 || m.TimeMonth > 11:
+		return fmt.Errorf("%w: timestamp fields out of range", ErrImplausible)
+	}
+
+	created := m.CreatedAt()
+	if created.Year() < earliestYear || created.After(now.Add(clockSkew)) {
+		return fmt.Errorf("%w: created %s", ErrImplausible, created.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Extract copies the estimated extent of hit out of r to w.
+func Extract(r io.ReaderAt, hit Hit, w io.Writer) error {
+	if _, err := io.Copy(w, io.NewSectionReader(r, hit.Offset, hit.Length)); err != nil {
+		return fmt.Errorf("err extracting archive at offset %d: %w", hit.Offset, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package carve_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/carve"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var now = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local)
+
+func embedded(database string, compression int) []byte {
+	return (&dumptest.Archive{
+		Database:    database,
+		VMin:        14,
+		Compression: compression,
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop"},
+			{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", Data: []byte("1\n2\n")},
+		},
+	}).Bytes()
+}
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	first := embedded("shop", 0)
+	second := embedded("billing", -1)
+	future := (&dumptest.Archive{Created: now.AddDate(1, 0, 0)}).Bytes()
+
+	var input bytes.Buffer
+	input.Write(bytes.Repeat([]byte{0xee}, 100))
+	input.Write(first)
+	input.WriteString("junk PGDMP junk")
+	secondAt := input.Len()
+	input.Write(second)
+	input.Write(future)
+	input.Write(bytes.Repeat([]byte{0}, 50))
+	data := input.Bytes()
+
+	res, err := carve.Scan(bytes.NewReader(data), int64(len(data)), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Hits) != 2 || res.Rejected != 2 {
+		t.Fatalf("expected 2 hits and 2 rejected, got=%+v", res)
+	}
+
+	testCases := []struct {
+		database string
+		offset   int
+		length   int
+	}{
+		{database: "shop", offset: 100, length: len(first)},
+		{database: "billing", offset: secondAt, length: len(second)},
+	}
+	for i, tC := range testCases {
+		hit := res.Hits[i]
+		if *hit.Metadata.DatabaseName != tC.database || hit.Offset != int64(tC.offset) || hit.Length != int64(tC.length) || !hit.Complete {
+			t.Errorf("expected %s at %d of %d bytes, got=%+v", tC.database, tC.offset, tC.length, hit)
+		}
+
+		var out bytes.Buffer
+		if err := carve.Extract(bytes.NewReader(data), hit, &out); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), data[tC.offset:tC.offset+tC.length]) {
+			t.Errorf("%s: extracted archive differs", tC.database)
+		}
+	}
+}
+
+func TestScanBeforeCreationTime(t *testing.T) {
+	t.Parallel()
+
+	data := (&dumptest.Archive{
+		VMin: 3,
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE", Owner: "shop"},
+			{Tag: "orders", Desc: "TABLE DATA", Owner: "shop", Data: []byte("1\n2\n")},
+		},
+	}).Bytes()
+
+	res, err := carve.Scan(bytes.NewReader(data), int64(len(data)), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Hits) != 1 || !res.Hits[0].Complete || res.Hits[0].Length != int64(len(data)) {
+		t.Fatalf("expected 1 complete hit of %d bytes, got=%+v", len(data), res)
+	}
+}
+
+func TestScanTruncated(t *testing.T) {
+	t.Parallel()
+
+	data := embedded("shop", -1)
+	data = data[:len(data)-4]
+
+	res, err := carve.Scan(bytes.NewReader(data), int64(len(data)), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Hits) != 1 || res.Hits[0].Complete {
+		t.Fatalf("expected 1 incomplete hit, got=%+v", res)
+	}
+}
+
+func TestPlausible(t *testing.T) {
+	t.Parallel()
+
+	valid := func() metadata.Metadata {
+		return metadata.Metadata{
+			Format: "CUSTOM", VMain: 1, VMin: 14, IntSize: 4, OffSize: 8,
+			TimeYear: 2021, TimeMonth: 5, TimeDay: 3, TimeHour: 18,
+		}
+	}
+
+	testCases := []struct {
+		desc   string
+		modify func(m *metadata.Metadata)
+		err    error
+	}{
+		{desc: "valid", modify: func(*metadata.Metadata) {}},
+		{desc: "intsize", modify: func(m *metadata.Metadata) { m.IntSize = 3 }, err: carve.ErrImplausible},
+		{desc: "version", modify: func(m *metadata.Metadata) { m.VMin = 40 }, err: carve.ErrImplausible},
+		{desc: "format", modify: func(m *metadata.Metadata) { m.Format = "NULL" }, err: carve.ErrImplausible},
+		{desc: "month", modify: func(m *metadata.Metadata) { m.TimeMonth = 12 }, err: carve.ErrImplausible},
+		{desc: "too old", modify: func(m *metadata.Metadata) { m.TimeYear = 1970 }, err: carve.ErrImplausible},
+		{desc: "toccount", modify: func(m *metadata.Metadata) { m.TOCCount = -1 }, err: carve.ErrImplausible},
+		{desc: "before creation time", modify: func(m *metadata.Metadata) {
+			*m = metadata.Metadata{Format: "CUSTOM", VMain: 1, VMin: 3, IntSize: 4, OffSize: 4}
+		}},
+	}
+	for _, tC := range testCases {
+		m := valid()
+		tC.modify(&m)
+
+		if err := carve.Plausible(&m, now); !errors.Is(err, tC.err) {
+			t.Errorf("%s: expected=%v, got=%v", tC.desc, tC.err, err)
+		}
+	}
+}
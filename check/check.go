@@ -0,0 +1,256 @@
+// Package check evaluates dumps against freshness thresholds and reports the
+// result in the Nagios plugin format.
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+)
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Status is a Nagios plugin status, doubling as the process exit code.
+type Status int
+
+const (
+	OK Status = iota
+	Warning
+	Critical
+	Unknown
+)
+
+// String returns the plugin output name of the status.
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case Warning:
+		return "WARNING"
+	case Critical:
+		return "CRITICAL"
+	case Unknown:
+		return "UNKNOWN"
+	}
+
+	return "UNKNOWN"
+}
+
+// Cfg holds the thresholds for a check.
+type Cfg struct {
+	// Path is a dump, or a directory of dumps checked per database.
+	Path string
+	// Database is the expected database name, if set.
+	Database string
+	// WarnAge is the age after which a dump is WARNING, if non-zero.
+	WarnAge time.Duration
+	// CritAge is the age after which a dump is CRITICAL, if non-zero.
+	CritAge time.Duration
+	// MinTOCCount is the minimum number of TOC entries, if non-zero.
+	MinTOCCount int
+	// MinSize is the minimum size of the dump in bytes, if non-zero.
+	MinSize int64
+	// Sources configures how remote paths are opened.
+	Sources extractor.SourceOptions
+}
+
+// Validate ensures that Cfg struct is valid.
+func (c *Cfg) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("%w: file not specified", ErrInvalidConfig)
+	}
+
+	if c.WarnAge < 0 || c.CritAge < 0 || c.MinTOCCount < 0 || c.MinSize < 0 {
+		return fmt.Errorf("%w: thresholds must not be negative", ErrInvalidConfig)
+	}
+
+	if c.WarnAge != 0 && c.CritAge != 0 && c.WarnAge > c.CritAge {
+		return fmt.Errorf("%w: warning age exceeds critical age", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Summary  string
+	Perfdata []string
+	Status   Status
+}
+
+// String returns the one-line plugin output.
+func (r *Result) String() string {
+	line := fmt.Sprintf("PGDUMP %s - %s", r.Status, r.Summary)
+	if len(r.Perfdata) > 0 {
+		line += " | " + strings.Join(r.Perfdata, " ")
+	}
+
+	return line
+}
+
+// Run checks the dump or directory of dumps at cfg.Path as of now. For a
+// directory of dumps, the newest dump of each database is checked and the
+// worst status wins.
+func Run(ctx context.Context, cfg *Cfg, now time.Time) Result {
+	var dumps []extractor.Dump
+
+	if info, err := os.Stat(cfg.Path); err == nil && info.IsDir() && !extractor.IsDumpDir(cfg.Path) {
+		if dumps, err = extractor.ScanDir(ctx, cfg.Path, cfg.Sources); err != nil {
+			return Result{Status: Unknown, Summary: err.Error()}
+		}
+	} else {
+		dump, scanErr := extractor.ScanFile(ctx, cfg.Path, cfg.Sources)
+		if scanErr != nil {
+			return Result{Status: Unknown, Summary: scanErr.Error()}
+		}
+		dumps = []extractor.Dump{dump}
+	}
+
+	res := Result{Status: OK}
+	var messages []string
+
+	for i := range dumps {
+		if dumps[i].Err != nil {
+			res.Status = worst(res.Status, Warning)
+			messages = append(messages, fmt.Sprintf("%s unreadable: %v", dumps[i].Path, dumps[i].Err))
+		}
+	}
+
+	latest := extractor.LatestByDatabase(dumps)
+	if cfg.Database != "" {
+		latest = filterDatabase(latest, cfg.Database)
+	}
+
+	if len(latest) == 0 {
+		res.Status = Critical
+		if cfg.Database != "" {
+			messages = append(messages, fmt.Sprintf("no dump of database %s", cfg.Database))
+		} else {
+			messages = append(messages, "no dumps found")
+		}
+	}
+
+	for i := range latest {
+		status, message := evaluate(cfg, &latest[i], now)
+		res.Status = worst(res.Status, status)
+		messages = append(messages, message)
+		res.Perfdata = append(res.Perfdata, perfdata(cfg, &latest[i], now)...)
+	}
+
+	res.Summary = strings.Join(messages, "; ")
+
+	return res
+}
+
+func evaluate(cfg *Cfg, dump *extractor.Dump, now time.Time) (Status, string) {
+	status := OK
+	var problems []string
+
+	age, known := dumpAge(dump, now)
+	switch {
+	case !known && (cfg.CritAge != 0 || cfg.WarnAge != 0):
+		status = worst(status, Unknown)
+		problems = append(problems, "creation time not recorded")
+	case cfg.CritAge != 0 && age > cfg.CritAge:
+		status = worst(status, Critical)
+		problems = append(problems, fmt.Sprintf("older than %s", cfg.CritAge))
+	case cfg.WarnAge != 0 && age > cfg.WarnAge:
+		status = worst(status, Warning)
+		problems = append(problems, fmt.Sprintf("older than %s", cfg.WarnAge))
+	}
+
+	if cfg.MinTOCCount != 0 && dump.Metadata.TOCCount < cfg.MinTOCCount {
+		status = worst(status, Critical)
+		problems = append(problems, fmt.Sprintf("fewer than %d TOC entries", cfg.MinTOCCount))
+	}
+
+	if cfg.MinSize != 0 && dump.Size < cfg.MinSize {
+		status = worst(status, Critical)
+		problems = append(problems, fmt.Sprintf("smaller than %d bytes", cfg.MinSize))
+	}
+
+	ageText := "unknown age"
+	if known {
+		ageText = fmt.Sprintf("%s old", age)
+	}
+	message := fmt.Sprintf("%s: %s, %d TOC entries, %d bytes",
+		dump.Database(), ageText, dump.Metadata.TOCCount, dump.Size)
+	if len(problems) > 0 {
+		message += " (" + strings.Join(problems, ", ") + ")"
+	}
+
+	return status, message
+}
+
+func perfdata(cfg *Cfg, dump *extractor.Dump, now time.Time) []string {
+	label := dump.Database()
+
+	// U marks a value the plugin couldn't determine.
+	ageValue := "U"
+	if age, known := dumpAge(dump, now); known {
+		ageValue = fmt.Sprintf("%ds", int64(age.Seconds()))
+	}
+
+	return []string{
+		fmt.Sprintf("'%s_age'=%s;%s;%s;0", label, ageValue, seconds(cfg.WarnAge), seconds(cfg.CritAge)),
+		fmt.Sprintf("'%s_toc'=%d;;%s;0", label, dump.Metadata.TOCCount, lowerBound(int64(cfg.MinTOCCount))),
+		fmt.Sprintf("'%s_size'=%dB;;%s;0", label, dump.Size, lowerBound(cfg.MinSize)),
+	}
+}
+
+// dumpAge returns the age of dump as of now, and false if the archive is
+// too old to record when it was created.
+func dumpAge(dump *extractor.Dump, now time.Time) (time.Duration, bool) {
+	if !dump.Metadata.HasCreatedAt() {
+		return 0, false
+	}
+
+	age := now.Sub(dump.Metadata.CreatedAt()).Truncate(time.Second)
+	if age < 0 {
+		return 0, true
+	}
+
+	return age, true
+}
+
+func seconds(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d", int64(d.Seconds()))
+}
+
+// lowerBound formats a minimum as a Nagios threshold range that alerts
+// when the value falls below it.
+func lowerBound(v int64) string {
+	if v == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d:", v)
+}
+
+func filterDatabase(dumps []extractor.Dump, database string) []extractor.Dump {
+	out := make([]extractor.Dump, 0, 1)
+	for _, dump := range dumps {
+		if dump.Database() == database {
+			out = append(out, dump)
+		}
+	}
+
+	return out
+}
+
+func worst(a, b Status) Status {
+	if b > a {
+		return b
+	}
+
+	return a
+}
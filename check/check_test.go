@@ -0,0 +1,224 @@
+package check_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/check"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+)
+
+var now = time.Date(2021, time.June, 4, 12, 0, 0, 0, time.Local)
+
+func writeDump(t *testing.T, dir, name string, archive *dumptest.Archive) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, archive.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestCfgValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		err    error
+		desc   string
+		config check.Cfg
+	}{
+		{
+			desc:   "no path",
+			config: check.Cfg{},
+			err:    check.ErrInvalidConfig,
+		},
+		{
+			desc:   "warning above critical",
+			config: check.Cfg{Path: "latest.dump", WarnAge: 2 * time.Hour, CritAge: time.Hour},
+			err:    check.ErrInvalidConfig,
+		},
+		{
+			desc:   "negative size",
+			config: check.Cfg{Path: "latest.dump", MinSize: -1},
+			err:    check.ErrInvalidConfig,
+		},
+		{
+			desc:   "valid",
+			config: check.Cfg{Path: "latest.dump", WarnAge: time.Hour, CritAge: 2 * time.Hour},
+			err:    nil,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res := tC.config.Validate()
+			if !errors.Is(res, tC.err) {
+				t.Errorf("expected=%v, got=%v", tC.err, res)
+			}
+		})
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := writeDump(t, dir, "app.dump", &dumptest.Archive{
+		Database: "app",
+		Created:  now.Add(-2 * time.Hour),
+		TOCCount: 15,
+	})
+
+	testCases := []struct {
+		desc   string
+		config check.Cfg
+		status check.Status
+	}{
+		{
+			desc:   "fresh",
+			config: check.Cfg{WarnAge: 24 * time.Hour, CritAge: 48 * time.Hour},
+			status: check.OK,
+		},
+		{
+			desc:   "warning age",
+			config: check.Cfg{WarnAge: time.Hour, CritAge: 48 * time.Hour},
+			status: check.Warning,
+		},
+		{
+			desc:   "critical age",
+			config: check.Cfg{WarnAge: 30 * time.Minute, CritAge: time.Hour},
+			status: check.Critical,
+		},
+		{
+			desc:   "too few TOC entries",
+			config: check.Cfg{MinTOCCount: 20},
+			status: check.Critical,
+		},
+		{
+			desc:   "too small",
+			config: check.Cfg{MinSize: 1 << 20},
+			status: check.Critical,
+		},
+		{
+			desc:   "wrong database",
+			config: check.Cfg{Database: "other"},
+			status: check.Critical,
+		},
+		{
+			desc:   "expected database",
+			config: check.Cfg{Database: "app"},
+			status: check.OK,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := tC.config
+			cfg.Path = path
+
+			res := check.Run(context.Background(), &cfg, now)
+			if res.Status != tC.status {
+				t.Errorf("expected=%v, got=%v (%s)", tC.status, res.Status, res.String())
+			}
+		})
+	}
+}
+
+func TestRunDirectory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeDump(t, dir, "app-old.dump", &dumptest.Archive{Database: "app", Created: now.Add(-72 * time.Hour), TOCCount: 15})
+	writeDump(t, dir, "app-new.dump", &dumptest.Archive{Database: "app", Created: now.Add(-time.Hour), TOCCount: 15})
+	writeDump(t, dir, "billing.dump", &dumptest.Archive{Database: "billing", Created: now.Add(-30 * time.Hour), TOCCount: 9})
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a dump at all"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := check.Cfg{Path: dir, WarnAge: 26 * time.Hour, CritAge: 50 * time.Hour}
+	res := check.Run(context.Background(), &cfg, now)
+
+	if res.Status != check.Warning {
+		t.Errorf("expected=%v, got=%v", check.Warning, res.Status)
+	}
+
+	line := res.String()
+	exp := "PGDUMP WARNING - app: 1h0m0s old, 15 TOC entries"
+	if !strings.HasPrefix(line, exp) {
+		t.Errorf("expected prefix=%q, got=%q", exp, line)
+	}
+	if !strings.Contains(line, "| 'app_age'=3600s;93600;180000;0 'app_toc'=15;;;0") {
+		t.Errorf("unexpected perfdata: %q", line)
+	}
+	if !strings.Contains(line, "billing: 30h0m0s old, 9 TOC entries, ") {
+		t.Errorf("expected billing to be reported: %q", line)
+	}
+}
+
+func TestRunTruncated(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeDump(t, dir, "app.dump", &dumptest.Archive{Database: "app", Created: now, TOCCount: 15})
+	full := (&dumptest.Archive{Database: "app", Created: now, TOCCount: 15}).Bytes()
+	if err := os.WriteFile(filepath.Join(dir, "partial.dump"), full[:20], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := check.Cfg{Path: dir}
+	res := check.Run(context.Background(), &cfg, now)
+	if res.Status != check.Warning {
+		t.Errorf("expected=%v, got=%v (%s)", check.Warning, res.Status, res.String())
+	}
+}
+
+func TestRunMissing(t *testing.T) {
+	t.Parallel()
+
+	cfg := check.Cfg{Path: filepath.Join(t.TempDir(), "missing.dump")}
+	res := check.Run(context.Background(), &cfg, now)
+	if res.Status != check.Unknown {
+		t.Errorf("expected=%v, got=%v", check.Unknown, res.Status)
+	}
+}
+
+func TestRunBeforeCreationTime(t *testing.T) {
+	t.Parallel()
+
+	// Archives before version 1.4 record no creation time.
+	path := writeDump(t, t.TempDir(), "legacy.dump", &dumptest.Archive{VMin: 3, TOCCount: 15})
+
+	testCases := []struct {
+		desc   string
+		config check.Cfg
+		status check.Status
+	}{
+		{desc: "age thresholds", config: check.Cfg{WarnAge: time.Hour, CritAge: 48 * time.Hour}, status: check.Unknown},
+		{desc: "no age thresholds", config: check.Cfg{MinTOCCount: 10}, status: check.OK},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := tC.config
+			cfg.Path = path
+
+			res := check.Run(context.Background(), &cfg, now)
+			if res.Status != tC.status {
+				t.Errorf("expected=%v, got=%v (%s)", tC.status, res.Status, res.String())
+			}
+			if line := res.String(); !strings.Contains(line, "unknown age") || !strings.Contains(line, "_age'=U;") {
+				t.Errorf("expected an unknown age: %q", line)
+			}
+		})
+	}
+}
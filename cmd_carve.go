@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/carve"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+)
+
+// runCarve runs the carve subcommand.
+func runCarve(args []string) {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("carve", flag.ExitOnError)
+	src.register(fs)
+	extractDir := fs.String("extract-dir", "", "directory to extract each archive found to, as <offset>.dump")
+	_ = fs.Parse(args)
+
+	if err := carveArchives(&src, *extractDir); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func carveArchives(flags *sourceFlags, extractDir string) error {
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	at, err := extractor.RandomAccess(src)
+	if err != nil {
+		return err
+	}
+
+	res, err := carve.Scan(at, src.Size(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	if extractDir != "" {
+		if err := os.MkdirAll(extractDir, 0o700); err != nil {
+			return fmt.Errorf("err creating extract directory: %w", err)
+		}
+		for _, hit := range res.Hits {
+			path := filepath.Join(extractDir, fmt.Sprintf("%d.dump", hit.Offset))
+			if err := extractHit(at, hit, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return printJSON(res)
+}
+
+func extractHit(r io.ReaderAt, hit carve.Hit, path string) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("err creating %s: %w", path, err)
+	}
+
+	if err := carve.Extract(r, hit, out); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("err writing %s: %w", path, err)
+	}
+
+	return nil
+}
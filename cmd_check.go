@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/check"
+)
+
+// runCheck runs the check subcommand, returning the plugin exit code.
+func runCheck(args []string) int {
+	cfg := check.Cfg{}
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.StringVar(&cfg.Path, "filename", "", "dump, URL, or directory of dumps, to check")
+	fs.StringVar(&cfg.Database, "database", "", "expected database name")
+	fs.DurationVar(&cfg.WarnAge, "warning", 26*time.Hour, "age after which a dump is WARNING (0 disables)")
+	fs.DurationVar(&cfg.CritAge, "critical", 50*time.Hour, "age after which a dump is CRITICAL (0 disables)")
+	fs.IntVar(&cfg.MinTOCCount, "min-toc", 0, "minimum number of TOC entries")
+	fs.Int64Var(&cfg.MinSize, "min-size", 0, "minimum dump size in bytes")
+	fs.StringVar(&cfg.Sources.S3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL, addressed path-style")
+	fs.StringVar(&cfg.Sources.S3Region, "s3-region", "", "S3 signing region (default $AWS_REGION or us-east-1)")
+	_ = fs.Parse(args)
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Printf("PGDUMP %s - %v\n", check.Unknown, err)
+		return int(check.Unknown)
+	}
+
+	res := check.Run(context.Background(), &cfg, time.Now())
+	fmt.Println(res.String())
+
+	return int(res.Status)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+// sourceFlags are the input flags shared by commands reading a single dump.
+type sourceFlags struct {
+	cfg extractor.Cfg
+}
+
+func (s *sourceFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.cfg.FileName, "filename", "", "dump, split parts glob or list, http(s) URL, or s3://bucket/key to read")
+	fs.BoolVar(&s.cfg.Stdin, "stdin", false, "configure to read from stdin")
+	fs.StringVar(&s.cfg.S3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL, addressed path-style")
+	fs.StringVar(&s.cfg.S3Region, "s3-region", "", "S3 signing region (default $AWS_REGION or us-east-1)")
+}
+
+// open validates the flags and opens the named source.
+func (s *sourceFlags) open(ctx context.Context) (extractor.Source, error) {
+	if err := s.cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	name := s.cfg.FileName
+	if s.cfg.Stdin {
+		name = "-"
+	}
+
+	return extractor.OpenSource(ctx, name, s.cfg.SourceOptions())
+}
+
+// printJSON writes v to stdout as a single line of JSON.
+func printJSON(v any) error {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("err dumping JSON: %w", err)
+	}
+
+	fmt.Printf("%s\n", out)
+
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// matchTables returns a match for extractor.Tables accepting the tables
+// named, qualified by their schema, or every table when names is empty. Once
+// the tables have been walked, missing reports the first named table that
+// wasn't seen.
+func matchTables(names []string) (match func(*metadata.TOCEntry) bool, missing func() error) {
+	seen := map[string]bool{}
+	for _, name := range names {
+		seen[name] = false
+	}
+
+	missing = func() error {
+		for _, name := range names {
+			if !seen[name] {
+				return fmt.Errorf("%w: %s", extractor.ErrTableNotFound, name)
+			}
+		}
+		return nil
+	}
+	if len(names) == 0 {
+		return nil, missing
+	}
+
+	return func(entry *metadata.TOCEntry) bool {
+		name := entry.QualifiedName()
+		if _, ok := seen[name]; !ok {
+			return false
+		}
+		seen[name] = true
+		return true
+	}, missing
+}
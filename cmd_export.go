@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/mble/pgdump-metadata-extractor/exporter"
+)
+
+// stringList is a flag.Value collecting repeated string flags.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+
+	return nil
+}
+
+// runExport runs the export subcommand.
+func runExport(args []string) {
+	cfg := exporter.Cfg{}
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Var((*stringList)(&cfg.Dirs), "dir", "backup directory to scan (repeatable)")
+	fs.StringVar(&cfg.Output, "output", "", "textfile collector .prom file to write")
+	_ = fs.Parse(args)
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := exporter.Run(context.Background(), &cfg); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var errNoTable = errors.New("-table is required")
+
+// runExtract runs the extract subcommand.
+func runExtract(args []string) {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	src.register(fs)
+	table := fs.String("table", "", "schema-qualified table to extract, e.g. public.orders")
+	format := fs.String("format", copydata.FormatCSV, "output format: csv or ndjson")
+	_ = fs.Parse(args)
+
+	if err := extract(&src, *table, *format, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// extract writes the rows of table to w in format.
+func extract(flags *sourceFlags, table, format string, w io.Writer) error {
+	if table == "" {
+		return errNoTable
+	}
+	if _, err := copydata.NewWriter(io.Discard, format, nil); err != nil {
+		return err
+	}
+
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return extractor.Table(src, table, func(entry *metadata.TOCEntry, data io.Reader) error {
+		columns, err := copydata.Columns(entry.CopyStmt)
+		if err != nil {
+			return err
+		}
+
+		out, err := copydata.NewWriter(w, format, columns)
+		if err != nil {
+			return err
+		}
+
+		r := copydata.NewReader(data)
+		for {
+			row, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				return out.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+)
+
+var errIndexNeedsFile = errors.New("-write-index requires -filename to name a local file")
+
+// runOffsets runs the offsets subcommand.
+func runOffsets(args []string) {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("offsets", flag.ExitOnError)
+	src.register(fs)
+	writeIndex := fs.Bool("write-index", false, "write the offsets to a sidecar index next to the dump")
+	_ = fs.Parse(args)
+
+	if err := offsets(&src, *writeIndex); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func offsets(flags *sourceFlags, writeIndex bool) error {
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	file, isFile := src.(*extractor.FileSource)
+	if writeIndex && !isFile {
+		return errIndexNeedsFile
+	}
+
+	r, err := extractor.OpenReader(src)
+	if err != nil {
+		return err
+	}
+
+	idx, err := archive.ScanOffsets(r)
+	if err != nil {
+		return err
+	}
+
+	if writeIndex {
+		a, err := archive.Open(file, file.Size())
+		if err != nil {
+			return err
+		}
+		if idx.Header, err = a.HeaderSum(); err != nil {
+			return err
+		}
+		if err := archive.WriteIndex(file.Name()+archive.IndexSuffix, idx); err != nil {
+			return err
+		}
+	}
+
+	return printJSON(idx)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+	"github.com/mble/pgdump-metadata-extractor/profile"
+)
+
+// runProfile runs the profile subcommand.
+func runProfile(args []string) {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	src.register(fs)
+	tables := fs.String("table", "", "comma-separated schema-qualified tables to profile (default all)")
+	_ = fs.Parse(args)
+
+	if err := profileTables(&src, splitList(*tables)); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// profileTables prints the column profiles of the named tables, or of every
+// table when names is empty.
+func profileTables(flags *sourceFlags, names []string) error {
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	match, missing := matchTables(names)
+	profiles := []profile.Table{}
+	err = extractor.Tables(src, match, func(entry *metadata.TOCEntry, data io.Reader) error {
+		columns, err := copydata.Columns(entry.CopyStmt)
+		if err != nil {
+			return err
+		}
+
+		p := profile.NewProfiler(columns)
+		r := copydata.NewReader(data)
+		for {
+			row, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			p.Add(row)
+		}
+
+		table := p.Table()
+		table.Namespace, table.Tag = entry.Namespace, entry.Tag
+		profiles = append(profiles, table)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := missing(); err != nil {
+		return err
+	}
+
+	return printJSON(map[string][]profile.Table{"tables": profiles})
+}
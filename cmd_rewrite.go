@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+)
+
+var errNoOutput = errors.New("-output is required")
+
+// runRewrite runs the rewrite subcommand.
+func runRewrite(args []string) {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	src.register(fs)
+	output := fs.String("output", "", "path of the archive to write, which may be the input itself")
+	_ = fs.Parse(args)
+
+	if err := rewrite(&src, *output); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// rewrite copies the input to output with its TOC data offsets filled in,
+// writing to a temporary file first so output is only replaced once the
+// rewritten archive has been verified.
+func rewrite(flags *sourceFlags, output string) error {
+	if output == "" {
+		return errNoOutput
+	}
+
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(output), ".pgdump-*.dump.tmp")
+	if err != nil {
+		return fmt.Errorf("err creating output: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	idx, err := archive.Rewrite(tmp, src)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("err writing output: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), output); err != nil {
+		return fmt.Errorf("err writing output: %w", err)
+	}
+
+	return printJSON(idx)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+// tableRows is the row count of one table.
+type tableRows struct {
+	Namespace string `json:"namespace,omitempty"`
+	Tag       string `json:"tag"`
+	DumpID    int    `json:"dumpId"`
+	copydata.Count
+}
+
+// runRows runs the rows subcommand.
+func runRows(args []string) {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("rows", flag.ExitOnError)
+	src.register(fs)
+	_ = fs.Parse(args)
+
+	if err := rows(&src); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func rows(flags *sourceFlags) error {
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	counts := []tableRows{}
+	err = extractor.Tables(src, nil, func(entry *metadata.TOCEntry, data io.Reader) error {
+		count, countErr := copydata.CountRows(data)
+		if countErr != nil {
+			return countErr
+		}
+
+		counts = append(counts, tableRows{Namespace: entry.Namespace, Tag: entry.Tag, DumpID: entry.DumpID, Count: count})
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return printJSON(map[string][]tableRows{"tables": counts})
+}
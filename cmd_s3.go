@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+	"github.com/mble/pgdump-metadata-extractor/s3"
+)
+
+// listedMetadata is the metadata of one object in a prefix scan.
+type listedMetadata struct {
+	Source string `json:"source"`
+	metadata.Metadata
+}
+
+// runS3Prefix prints the metadata of every object under a bucket prefix as
+// JSON lines, logging objects that can't be read.
+func runS3Prefix(cfg *extractor.Cfg) error {
+	ctx := context.Background()
+
+	bucket, prefix, err := s3.ParseURL(cfg.FileName)
+	if err != nil {
+		return err
+	}
+
+	client, err := s3.NewClientFromEnv(cfg.S3Endpoint, cfg.S3Region)
+	if err != nil {
+		return err
+	}
+
+	objects, err := client.List(ctx, bucket, prefix)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, object := range objects {
+		source := fmt.Sprintf("s3://%s/%s", bucket, object.Key)
+
+		r, openErr := client.Open(ctx, bucket, object.Key)
+		if openErr != nil {
+			log.Printf("%s: %v", source, openErr)
+			failed++
+			continue
+		}
+
+		data, extractErr := extractor.Extract(extractor.NewRemoteSource(source, r))
+		if extractErr != nil {
+			log.Printf("%s: %v", source, extractErr)
+			failed++
+			continue
+		}
+
+		out, jsonErr := json.Marshal(listedMetadata{Source: source, Metadata: data})
+		if jsonErr != nil {
+			return fmt.Errorf("err dumping JSON: %w", jsonErr)
+		}
+
+		fmt.Printf("%s\n", out)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d objects could not be read", failed, len(objects))
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+)
+
+var errNoOutputDir = errors.New("-output-dir is required")
+
+// Exit codes of the salvage subcommand.
+const (
+	salvageOK      = 0
+	salvageLost    = 1
+	salvageErrored = 2
+)
+
+// runSalvage runs the salvage subcommand, returning its exit code.
+func runSalvage(args []string) int {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("salvage", flag.ExitOnError)
+	src.register(fs)
+	outputDir := fs.String("output-dir", "", "directory to write the COPY data of recovered blocks to")
+	_ = fs.Parse(args)
+
+	report, err := salvage(&src, *outputDir)
+	if err != nil {
+		log.Print(err)
+		return salvageErrored
+	}
+
+	if err := printJSON(report); err != nil {
+		log.Print(err)
+		return salvageErrored
+	}
+
+	if len(report.Lost) > 0 || len(report.Damaged) > 0 {
+		return salvageLost
+	}
+
+	return salvageOK
+}
+
+func salvage(flags *sourceFlags, outputDir string) (*archive.SalvageReport, error) {
+	if outputDir == "" {
+		return nil, errNoOutputDir
+	}
+
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	at, err := extractor.RandomAccess(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(outputDir, 0o700); err != nil {
+		return nil, fmt.Errorf("err creating output directory: %w", err)
+	}
+
+	return archive.Salvage(at, src.Size(), func(block archive.SalvagedBlock, data io.Reader) error {
+		if block.Type != archive.BlockData {
+			return nil
+		}
+
+		return writeSalvaged(filepath.Join(outputDir, salvagedFileName(block)), data)
+	})
+}
+
+// salvagedFileName names the file holding a recovered block after its dump
+// ID and, when the TOC entry was read, its table.
+func salvagedFileName(block archive.SalvagedBlock) string {
+	if block.Tag == "" {
+		return fmt.Sprintf("%d.copy", block.DumpID)
+	}
+
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, block.Tag)
+
+	return fmt.Sprintf("%d-%s.copy", block.DumpID, name)
+}
+
+func writeSalvaged(path string, data io.Reader) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("err creating %s: %w", path, err)
+	}
+
+	if _, err := io.Copy(out, data); err != nil {
+		_ = out.Close()
+		return fmt.Errorf("err writing %s: %w", path, err)
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("err writing %s: %w", path, err)
+	}
+
+	return nil
+}
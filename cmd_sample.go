@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var errInvalidSampleSize = errors.New("-n must be positive")
+
+// sampleOptions configure the sample subcommand.
+type sampleOptions struct {
+	table  string
+	format string
+	n      int
+	random bool
+	seed   uint64
+}
+
+// runSample runs the sample subcommand.
+func runSample(args []string) {
+	src := sourceFlags{}
+	opts := sampleOptions{}
+	fs := flag.NewFlagSet("sample", flag.ExitOnError)
+	src.register(fs)
+	fs.StringVar(&opts.table, "table", "", "schema-qualified table to sample, e.g. public.orders")
+	fs.StringVar(&opts.format, "format", copydata.FormatCSV, "output format: csv or ndjson")
+	fs.IntVar(&opts.n, "n", 10, "number of rows to return")
+	fs.BoolVar(&opts.random, "random", false, "sample rows uniformly from the whole table instead of taking the first")
+	fs.Uint64Var(&opts.seed, "seed", 0, "seed for -random, for a repeatable sample (default random)")
+	_ = fs.Parse(args)
+
+	if err := sample(&src, &opts, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// sample writes n rows of the table to w, the first ones or a random sample.
+func sample(flags *sourceFlags, opts *sampleOptions, w io.Writer) error {
+	if opts.table == "" {
+		return errNoTable
+	}
+	if opts.n <= 0 {
+		return errInvalidSampleSize
+	}
+	if _, err := copydata.NewWriter(io.Discard, opts.format, nil); err != nil {
+		return err
+	}
+
+	seed := opts.seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return extractor.Table(src, opts.table, func(entry *metadata.TOCEntry, data io.Reader) error {
+		columns, err := copydata.Columns(entry.CopyStmt)
+		if err != nil {
+			return err
+		}
+
+		r := copydata.NewReader(data)
+		var rows []copydata.Row
+		if opts.random {
+			rows, err = copydata.Sample(r, opts.n, rng)
+		} else {
+			rows, err = copydata.Head(r, opts.n)
+		}
+		if err != nil {
+			return err
+		}
+
+		out, err := copydata.NewWriter(w, opts.format, columns)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+
+		return out.Flush()
+	})
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+	"github.com/mble/pgdump-metadata-extractor/pii"
+)
+
+// Exit codes of the scan subcommand.
+const (
+	scanClean   = 0
+	scanFound   = 1
+	scanErrored = 2
+)
+
+// scanOptions are the flags of the scan subcommand.
+type scanOptions struct {
+	tables    []string
+	detectors []string
+	patterns  []string
+	examples  int
+}
+
+// runScan runs the scan subcommand, returning its exit code.
+func runScan(args []string) int {
+	src := sourceFlags{}
+	opts := scanOptions{}
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	src.register(fs)
+	tables := fs.String("table", "", "comma-separated schema-qualified tables to scan (default all)")
+	detectors := fs.String("detectors", "email,phone,card,ssn,nino,ipv4,ipv6", "comma-separated built-in detectors to run")
+	fs.Var((*stringList)(&opts.patterns), "pattern", "extra detector as name=regexp (repeatable)")
+	fs.IntVar(&opts.examples, "examples", pii.DefaultExamples, "example row offsets to report per column and detector")
+	_ = fs.Parse(args)
+	opts.tables = splitList(*tables)
+	opts.detectors = splitList(*detectors)
+
+	report, err := scan(&src, &opts)
+	if err != nil {
+		log.Print(err)
+		return scanErrored
+	}
+
+	if err := printJSON(report); err != nil {
+		log.Print(err)
+		return scanErrored
+	}
+
+	if !report.Clean() {
+		return scanFound
+	}
+
+	return scanClean
+}
+
+// scan runs the detectors of opts over the data of the tables of opts, or of
+// every table when none are named.
+func scan(flags *sourceFlags, opts *scanOptions) (*pii.Report, error) {
+	detectors, err := pii.Lookup(opts.detectors)
+	if err != nil {
+		return nil, err
+	}
+	for _, spec := range opts.patterns {
+		d, parseErr := pii.ParseDetector(spec)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		detectors = append(detectors, d)
+	}
+
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	match, missing := matchTables(opts.tables)
+	report := &pii.Report{Tables: []pii.Table{}}
+	err = extractor.Tables(src, match, func(entry *metadata.TOCEntry, data io.Reader) error {
+		columns, err := copydata.Columns(entry.CopyStmt)
+		if err != nil {
+			return err
+		}
+
+		s := pii.NewScanner(detectors, columns, opts.examples)
+		r := copydata.NewReader(data)
+		for {
+			row, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			s.Scan(row, r.Offset())
+		}
+
+		table := s.Table()
+		table.Namespace, table.Tag = entry.Namespace, entry.Tag
+		report.Add(table)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := missing(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/server"
+)
+
+// runServe runs the serve subcommand until interrupted.
+func runServe(args []string) {
+	cfg := server.Cfg{}
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&cfg.Addr, "addr", ":8080", "address to listen on")
+	fs.Int64Var(&cfg.MaxBytes, "max-bytes", 1<<20, "maximum request body bytes read per request")
+	fs.DurationVar(&cfg.Timeout, "timeout", 30*time.Second, "maximum time to read and answer a request")
+	_ = fs.Parse(args)
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	if err := server.Run(ctx, &cfg, logger); err != nil {
+		log.Fatal(err) //nolint:gocritic // stop only releases the signal handler
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+)
+
+// runSizes runs the sizes subcommand.
+func runSizes(args []string) {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("sizes", flag.ExitOnError)
+	src.register(fs)
+	top := fs.Int("top", 0, "only list the N largest tables (default all)")
+	_ = fs.Parse(args)
+
+	if err := sizes(&src, *top); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func sizes(flags *sourceFlags, top int) error {
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	report, err := tableSizes(src)
+	if err != nil {
+		return err
+	}
+	report.Top(top)
+
+	return printJSON(report)
+}
+
+// tableSizes walks the data blocks of a custom format archive, or measures
+// the data files of a dump that keeps its data in separate files.
+func tableSizes(src extractor.Source) (*archive.SizeReport, error) {
+	r, err := extractor.OpenReader(src)
+	if errors.Is(err, archive.ErrUnsupportedFormat) {
+		d, dirErr := extractor.OpenDir(src)
+		if dirErr != nil {
+			return nil, dirErr
+		}
+		return archive.DirSizes(d)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.Sizes(r)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/stats"
+)
+
+// runStats runs the stats subcommand.
+func runStats(args []string) {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	src.register(fs)
+	_ = fs.Parse(args)
+
+	if err := summarize(&src); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func summarize(flags *sourceFlags) error {
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	_, entries, err := extractor.ExtractTOC(src)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(stats.Summarize(entries))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+)
+
+// Exit codes of the verify subcommand.
+const (
+	verifyOK      = 0
+	verifyFailed  = 1
+	verifyErrored = 2
+)
+
+// runVerify runs the verify subcommand, returning its exit code.
+func runVerify(args []string) int {
+	src := sourceFlags{}
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	src.register(fs)
+	_ = fs.Parse(args)
+
+	report, err := verify(&src)
+	if err != nil {
+		log.Print(err)
+		return verifyErrored
+	}
+
+	if err := printJSON(report); err != nil {
+		log.Print(err)
+		return verifyErrored
+	}
+
+	if !report.OK() {
+		return verifyFailed
+	}
+
+	return verifyOK
+}
+
+func verify(flags *sourceFlags) (*archive.Report, error) {
+	src, err := flags.open(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return archive.Verify(src)
+}
@@ -0,0 +1,92 @@
+package copydata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidCopyStmt = errors.New("invalid COPY statement")
+
+// Columns returns the column names listed in a COPY statement, such as the
+// copyStmt of a TABLE DATA entry: COPY public.orders (id, "Total") FROM stdin;
+// Quoted identifiers are unquoted. A statement without a column list, as
+// written by old pg_dump versions, has no columns.
+func Columns(copyStmt string) ([]string, error) {
+	s := strings.TrimSpace(copyStmt)
+	if len(s) < len("COPY ") || !strings.EqualFold(s[:len("COPY ")], "COPY ") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCopyStmt, copyStmt)
+	}
+	s = s[len("COPY "):]
+
+	// Skip the possibly qualified, possibly quoted table name.
+	for {
+		s = strings.TrimLeft(s, " ")
+		_, rest, err := identifier(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", err, copyStmt)
+		}
+		s = rest
+		if !strings.HasPrefix(s, ".") {
+			break
+		}
+		s = s[1:]
+	}
+
+	s = strings.TrimLeft(s, " ")
+	if !strings.HasPrefix(s, "(") {
+		return nil, nil
+	}
+	s = s[1:]
+
+	var columns []string
+	for {
+		s = strings.TrimLeft(s, " ")
+		name, rest, err := identifier(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", err, copyStmt)
+		}
+		columns = append(columns, name)
+
+		s = strings.TrimLeft(rest, " ")
+		switch {
+		case strings.HasPrefix(s, ","):
+			s = s[1:]
+		case strings.HasPrefix(s, ")"):
+			return columns, nil
+		default:
+			return nil, fmt.Errorf("%w: unterminated column list in %q", ErrInvalidCopyStmt, copyStmt)
+		}
+	}
+}
+
+// identifier reads a quoted or bare identifier from the start of s,
+// returning it and the rest of s.
+func identifier(s string) (string, string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, " .,()")
+		if end < 0 {
+			end = len(s)
+		}
+		if end == 0 {
+			return "", s, fmt.Errorf("%w: missing identifier", ErrInvalidCopyStmt)
+		}
+		return s[:end], s[end:], nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '"' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '"' {
+			b.WriteByte('"')
+			i++
+			continue
+		}
+		return b.String(), s[i+1:], nil
+	}
+
+	return "", s, fmt.Errorf("%w: unterminated quoted identifier", ErrInvalidCopyStmt)
+}
@@ -0,0 +1,41 @@
+package copydata_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+)
+
+func TestColumns(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc string
+		stmt string
+		want []string
+		err  error
+	}{
+		{desc: "simple", stmt: "COPY public.orders (id, total) FROM stdin;\n", want: []string{"id", "total"}},
+		{desc: "quoted", stmt: `COPY "My Schema"."Orders" ("Id", "a ""b"", c", "x)y") FROM stdin;`, want: []string{"Id", `a "b", c`, "x)y"}},
+		{desc: "unqualified", stmt: "COPY orders (id) FROM stdin;", want: []string{"id"}},
+		{desc: "no column list", stmt: "COPY orders FROM stdin;"},
+		{desc: "not a COPY", stmt: "SELECT 1;", err: copydata.ErrInvalidCopyStmt},
+		{desc: "unterminated list", stmt: "COPY orders (id", err: copydata.ErrInvalidCopyStmt},
+		{desc: "unterminated quote", stmt: `COPY orders ("id) FROM stdin;`, err: copydata.ErrInvalidCopyStmt},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := copydata.Columns(tC.stmt)
+			if !errors.Is(err, tC.err) {
+				t.Fatalf("expected=%v, got=%v", tC.err, err)
+			}
+			if !reflect.DeepEqual(tC.want, got) {
+				t.Errorf("expected=%q, got=%q", tC.want, got)
+			}
+		})
+	}
+}
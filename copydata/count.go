@@ -0,0 +1,83 @@
+// Package copydata reads the text format of COPY, in which pg_dump stores
+// table data.
+package copydata
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// bufSize is how much COPY data is scanned at a time.
+const bufSize = 64 << 10
+
+// Count is the size of a table's COPY data.
+type Count struct {
+	// Rows is the number of rows.
+	Rows int64 `json:"rows"`
+	// Bytes is the size of the rows, up to any end-of-data marker.
+	Bytes int64 `json:"bytes"`
+}
+
+// CountRows counts the rows of the COPY data in r without decoding them.
+// Rows end at unescaped newlines; a backslash escapes the character after
+// it, newlines included, as COPY FROM does. Counting stops at a \. line
+// marking the end of the data.
+func CountRows(r io.Reader) (Count, error) {
+	var (
+		count     Count
+		buf       = make([]byte, bufSize)
+		pos       int64 // offset of buf[0] in the data
+		lineStart int64
+		// escaped is set after a backslash, whose escape started a line
+		// when lineEscape is also set.
+		escaped, lineEscape bool
+		// marker is set while the line so far is \. or \.\r.
+		marker bool
+	)
+
+	for {
+		n, err := r.Read(buf)
+		for i, c := range buf[:n] {
+			switch {
+			case escaped:
+				escaped = false
+				marker = lineEscape && c == '.'
+			case c == '\\':
+				escaped = true
+				lineEscape = pos+int64(i) == lineStart
+				marker = false
+			case c == '\n':
+				if marker {
+					count.Bytes = lineStart
+					return count, nil
+				}
+				count.Rows++
+				lineStart = pos + int64(i) + 1
+			case c == '\r' && marker:
+			default:
+				marker = false
+			}
+		}
+		pos += int64(n)
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("err reading COPY data: %w", err)
+		}
+	}
+
+	if marker {
+		count.Bytes = lineStart
+		return count, nil
+	}
+	if pos > lineStart {
+		// The last row has no trailing newline.
+		count.Rows++
+	}
+	count.Bytes = pos
+
+	return count, nil
+}
@@ -0,0 +1,54 @@
+package copydata_test
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+)
+
+func TestCountRows(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc  string
+		data  string
+		rows  int64
+		bytes int64
+	}{
+		{desc: "empty", data: ""},
+		{desc: "rows", data: "1\talice\n2\tbob\n", rows: 2, bytes: 14},
+		{desc: "no trailing newline", data: "1\talice\n2\tbob", rows: 2, bytes: 13},
+		{desc: "escaped newline sequence", data: "1\tline\\nbreak\n", rows: 1, bytes: 14},
+		{desc: "backslash before a newline", data: "1\tline\\\nbreak\n", rows: 1, bytes: 14},
+		{desc: "escaped backslash", data: "1\ta\\\\\n2\tb\n", rows: 2, bytes: 10},
+		{desc: "end marker", data: "1\n2\n\\.\n\n", rows: 2, bytes: 4},
+		{desc: "end marker without newline", data: "1\n2\n\\.", rows: 2, bytes: 4},
+		{desc: "end marker with CRLF", data: "1\r\n\\.\r\n", rows: 1, bytes: 3},
+		{desc: "dot mid-row", data: "a\\.b\n\\.x\n", rows: 2, bytes: 9},
+		{desc: "null", data: "\\N\t1\n", rows: 1, bytes: 5},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			// Reading a byte at a time checks escapes split across reads.
+			got, err := copydata.CountRows(iotest.OneByteReader(strings.NewReader(tC.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Rows != tC.rows || got.Bytes != tC.bytes {
+				t.Errorf("expected=%d rows/%d bytes, got=%d rows/%d bytes", tC.rows, tC.bytes, got.Rows, got.Bytes)
+			}
+		})
+	}
+}
+
+func TestCountRowsReadErr(t *testing.T) {
+	t.Parallel()
+
+	if _, err := copydata.CountRows(iotest.ErrReader(iotest.ErrTimeout)); err == nil {
+		t.Error("expected an error")
+	}
+}
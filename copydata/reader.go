@@ -0,0 +1,220 @@
+package copydata
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrInvalidEscape = errors.New("invalid escape sequence")
+
+// Field is a decoded column value.
+type Field struct {
+	// Value is the decoded text of the value.
+	Value string
+	// Null reports whether the value is NULL, written as \N.
+	Null bool
+}
+
+// Row is a decoded row of COPY data.
+type Row []Field
+
+// Reader decodes rows of COPY text format data: tab-separated columns, one
+// row per line, with backslash escapes and \N for NULL.
+type Reader struct {
+	br   *bufio.Reader
+	line []byte
+	done bool
+	// pos is the offset in the data of the next line.
+	pos int64
+	// offset is the offset in the data of the row last read.
+	offset int64
+}
+
+// NewReader returns a Reader decoding the COPY data in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReaderSize(r, bufSize)}
+}
+
+// Read returns the next row, or io.EOF once the data or a \. end marker is
+// reached.
+func (r *Reader) Read() (Row, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+
+	line, err := r.readLine()
+	if err != nil {
+		r.done = true
+		return nil, err
+	}
+	if isEndMarker(line) {
+		r.done = true
+		return nil, io.EOF
+	}
+
+	return decodeRow(line)
+}
+
+// Offset returns the position in the data of the row last read.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// readLine reads up to the next unescaped newline, returning the line without
+// it. A newline preceded by an escaping backslash belongs to the line.
+func (r *Reader) readLine() ([]byte, error) {
+	r.line = r.line[:0]
+	r.offset = r.pos
+
+	for {
+		chunk, err := r.br.ReadSlice('\n')
+		r.line = append(r.line, chunk...)
+		r.pos += int64(len(chunk))
+
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF):
+			if len(r.line) == 0 {
+				return nil, io.EOF
+			}
+			// The last row has no trailing newline.
+			return r.line, nil
+		case err != nil:
+			return nil, fmt.Errorf("err reading COPY data: %w", err)
+		}
+
+		body := r.line[:len(r.line)-1]
+		if trailingBackslashes(body)%2 == 0 {
+			return bytes.TrimSuffix(body, []byte("\r")), nil
+		}
+	}
+}
+
+func trailingBackslashes(b []byte) int {
+	n := 0
+	for n < len(b) && b[len(b)-1-n] == '\\' {
+		n++
+	}
+
+	return n
+}
+
+func isEndMarker(line []byte) bool {
+	return bytes.Equal(line, []byte(`\.`))
+}
+
+// decodeRow splits line at unescaped tabs and decodes each field.
+func decodeRow(line []byte) (Row, error) {
+	var row Row
+	start := 0
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '\t':
+			field, err := decodeField(line[start:i])
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, field)
+			start = i + 1
+		}
+	}
+
+	field, err := decodeField(line[start:])
+	if err != nil {
+		return nil, err
+	}
+
+	return append(row, field), nil
+}
+
+// decodeField decodes the backslash escapes of a field.
+func decodeField(raw []byte) (Field, error) {
+	if bytes.Equal(raw, []byte(`\N`)) {
+		return Field{Null: true}, nil
+	}
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return Field{Value: string(raw)}, nil
+	}
+
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' {
+			out = append(out, c)
+			continue
+		}
+
+		i++
+		if i == len(raw) {
+			return Field{}, fmt.Errorf("%w: trailing backslash", ErrInvalidEscape)
+		}
+
+		switch c = raw[i]; c {
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'v':
+			out = append(out, '\v')
+		case 'x':
+			val, n := digits(raw[i+1:], 16, 2)
+			if n == 0 {
+				// Like COPY, a \x without hex digits is a plain x.
+				out = append(out, 'x')
+				continue
+			}
+			out = append(out, byte(val))
+			i += n
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			val, n := digits(raw[i:], 8, 3)
+			out = append(out, byte(val))
+			i += n - 1
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return Field{Value: string(out)}, nil
+}
+
+// digits parses up to maxDigits leading digits of b in base, returning the value
+// and how many digits were used.
+func digits(b []byte, base, maxDigits int) (int, int) {
+	val, n := 0, 0
+	for n < len(b) && n < maxDigits {
+		d := digitValue(b[n])
+		if d < 0 || d >= base {
+			break
+		}
+		val = val*base + d
+		n++
+	}
+
+	return val, n
+}
+
+func digitValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+
+	return -1
+}
@@ -0,0 +1,104 @@
+package copydata_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+)
+
+func v(s string) copydata.Field { return copydata.Field{Value: s} }
+
+var null = copydata.Field{Null: true}
+
+func readAll(t *testing.T, data string) ([]copydata.Row, error) {
+	t.Helper()
+
+	r := copydata.NewReader(iotest.HalfReader(strings.NewReader(data)))
+	var rows []copydata.Row
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc string
+		data string
+		want []copydata.Row
+	}{
+		{desc: "empty", data: ""},
+		{desc: "rows", data: "1\talice\n2\tbob\n", want: []copydata.Row{{v("1"), v("alice")}, {v("2"), v("bob")}}},
+		{desc: "null and empty", data: "\\N\t\t\\\\N\n", want: []copydata.Row{{null, v(""), v(`\N`)}}},
+		{desc: "escapes", data: "a\\tb\\nc\\\\d\\re\\bf\\fg\\vh\n", want: []copydata.Row{{v("a\tb\nc\\d\re\bf\fg\vh")}}},
+		{desc: "octal and hex", data: "\\101\\x42\\0\\xg\n", want: []copydata.Row{{v("AB\x00xg")}}},
+		{desc: "other escaped characters", data: "\\a\\.\\,\n", want: []copydata.Row{{v("a.,")}}},
+		{desc: "escaped tab", data: "a\\\tb\tc\n", want: []copydata.Row{{v("a\tb"), v("c")}}},
+		{desc: "backslash before a newline", data: "a\\\nb\tc\n", want: []copydata.Row{{v("a\nb"), v("c")}}},
+		{desc: "no trailing newline", data: "1\n2", want: []copydata.Row{{v("1")}, {v("2")}}},
+		{desc: "end marker", data: "1\n\\.\n\n2\n", want: []copydata.Row{{v("1")}}},
+		{desc: "CRLF", data: "1\t2\r\n\\.\r\n", want: []copydata.Row{{v("1"), v("2")}}},
+		{desc: "empty row", data: "\n", want: []copydata.Row{{v("")}}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := readAll(t, tC.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tC.want, got) {
+				t.Errorf("expected=%+v, got=%+v", tC.want, got)
+			}
+		})
+	}
+}
+
+func TestReaderLongRow(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("x", 200<<10)
+	got, err := readAll(t, long+"\t1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0][0].Value != long || got[0][1].Value != "1" {
+		t.Errorf("unexpected rows: %d", len(got))
+	}
+}
+
+func TestReaderOffset(t *testing.T) {
+	t.Parallel()
+
+	r := copydata.NewReader(strings.NewReader("1\nlonger\n3\n"))
+	for _, want := range []int64{0, 2, 9} {
+		if _, err := r.Read(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Offset() != want {
+			t.Errorf("expected=%d, got=%d", want, r.Offset())
+		}
+	}
+}
+
+func TestReaderInvalidEscape(t *testing.T) {
+	t.Parallel()
+
+	if _, err := readAll(t, "a\\"); !errors.Is(err, copydata.ErrInvalidEscape) {
+		t.Errorf("expected=%v, got=%v", copydata.ErrInvalidEscape, err)
+	}
+}
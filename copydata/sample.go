@@ -0,0 +1,66 @@
+package copydata
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"sort"
+)
+
+// Head returns the first n rows of r.
+func Head(r *Reader, n int) ([]Row, error) {
+	rows := []Row{}
+	for len(rows) < n {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Sample reads every row of r and returns n of them, each row equally likely
+// to be chosen, in the order they were read. Fewer rows are returned when r
+// has fewer than n.
+func Sample(r *Reader, n int, rng *rand.Rand) ([]Row, error) {
+	type sampled struct {
+		row   Row
+		index int64
+	}
+
+	reservoir := make([]sampled, 0, n)
+	for index := int64(0); ; index++ {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Algorithm R: the first n rows fill the reservoir, and each later row
+		// replaces a random one with probability n/(index+1).
+		switch {
+		case len(reservoir) < n:
+			reservoir = append(reservoir, sampled{row: row, index: index})
+		case n > 0:
+			if j := rng.Int64N(index + 1); j < int64(n) {
+				reservoir[j] = sampled{row: row, index: index}
+			}
+		}
+	}
+
+	sort.Slice(reservoir, func(i, j int) bool { return reservoir[i].index < reservoir[j].index })
+
+	rows := make([]Row, len(reservoir))
+	for i := range reservoir {
+		rows[i] = reservoir[i].row
+	}
+
+	return rows, nil
+}
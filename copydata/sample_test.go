@@ -0,0 +1,104 @@
+package copydata_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+)
+
+func numberedRows(n int) string {
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+
+	return b.String()
+}
+
+func TestHead(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc string
+		rows int
+		n    int
+		want int
+	}{
+		{desc: "fewer rows than asked for", rows: 3, n: 10, want: 3},
+		{desc: "more rows than asked for", rows: 10, n: 3, want: 3},
+		{desc: "none", rows: 10, n: 0, want: 0},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rows, err := copydata.Head(copydata.NewReader(strings.NewReader(numberedRows(tC.rows))), tC.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != tC.want {
+				t.Fatalf("expected=%d, got=%d", tC.want, len(rows))
+			}
+			for i, row := range rows {
+				if row[0].Value != strconv.Itoa(i) {
+					t.Errorf("expected=%d, got=%s", i, row[0].Value)
+				}
+			}
+		})
+	}
+}
+
+func TestSample(t *testing.T) {
+	t.Parallel()
+
+	const rows, n, runs = 20, 5, 2000
+
+	// Every row should be picked about n/rows of the time.
+	picked := make([]int, rows)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range runs {
+		sample, err := copydata.Sample(copydata.NewReader(strings.NewReader(numberedRows(rows))), n, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sample) != n {
+			t.Fatalf("expected=%d, got=%d", n, len(sample))
+		}
+
+		prev := -1
+		for _, row := range sample {
+			i, err := strconv.Atoi(row[0].Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i <= prev {
+				t.Fatalf("expected rows in input order, got %d after %d", i, prev)
+			}
+			prev = i
+			picked[i]++
+		}
+	}
+
+	want := runs * n / rows
+	for i, count := range picked {
+		if count < want*3/4 || count > want*5/4 {
+			t.Errorf("row %d: expected about %d picks, got=%d", i, want, count)
+		}
+	}
+}
+
+func TestSampleFewerRows(t *testing.T) {
+	t.Parallel()
+
+	sample, err := copydata.Sample(copydata.NewReader(strings.NewReader(numberedRows(3))), 10, rand.New(rand.NewPCG(1, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sample) != 3 {
+		t.Errorf("expected=%d, got=%d", 3, len(sample))
+	}
+}
@@ -0,0 +1,175 @@
+package copydata
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrUnknownOutputFormat = errors.New("unknown output format")
+
+// Output formats of NewWriter.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Writer writes decoded rows in an output format.
+type Writer interface {
+	// Write writes a row.
+	Write(row Row) error
+	// Flush writes any buffered output.
+	Flush() error
+}
+
+// NewWriter returns a Writer for format, FormatCSV or FormatNDJSON, naming
+// the values by columns. Without columns, they are named column1, column2, …
+// after the number of values in the first row.
+func NewWriter(w io.Writer, format string, columns []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	case FormatNDJSON:
+		return &jsonWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownOutputFormat, format)
+}
+
+// columnNames returns columns, or generated names for n values.
+func columnNames(columns []string, n int) []string {
+	if columns != nil {
+		return columns
+	}
+
+	columns = make([]string, n)
+	for i := range columns {
+		columns[i] = ColumnName(i)
+	}
+
+	return columns
+}
+
+// ColumnName is the name given to the value at index i of rows whose COPY
+// statement lists no columns.
+func ColumnName(i int) string {
+	return "column" + strconv.Itoa(i+1)
+}
+
+// csvWriter writes RFC 4180 CSV with a header row. As with COPY's CSV
+// format, NULL is an empty unquoted value and an empty string is quoted, so
+// the two stay distinct.
+type csvWriter struct {
+	w       *bufio.Writer
+	columns []string
+	started bool
+}
+
+func (c *csvWriter) Write(row Row) error {
+	if !c.started {
+		c.columns = columnNames(c.columns, len(row))
+		if err := c.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	return c.writeRow(row)
+}
+
+// writeHeader writes the column names as the first row.
+func (c *csvWriter) writeHeader() error {
+	header := make(Row, len(c.columns))
+	for i, name := range c.columns {
+		header[i] = Field{Value: name}
+	}
+	c.started = true
+
+	return c.writeRow(header)
+}
+
+func (c *csvWriter) writeRow(row Row) error {
+	for i, field := range row {
+		if i > 0 {
+			_ = c.w.WriteByte(',')
+		}
+		if field.Null {
+			continue
+		}
+		if field.Value != "" && !strings.ContainsAny(field.Value, ",\"\r\n") {
+			_, _ = c.w.WriteString(field.Value)
+			continue
+		}
+
+		_ = c.w.WriteByte('"')
+		_, _ = c.w.WriteString(strings.ReplaceAll(field.Value, `"`, `""`))
+		_ = c.w.WriteByte('"')
+	}
+	_, err := c.w.WriteString("\r\n")
+
+	return err
+}
+
+// Flush writes the header of a table without rows, when its columns are
+// known, along with any buffered rows.
+func (c *csvWriter) Flush() error {
+	if !c.started && c.columns != nil {
+		if err := c.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	return c.w.Flush()
+}
+
+// jsonWriter writes one JSON object per row, keyed by column name in column
+// order, with NULL as null.
+type jsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+	keys    [][]byte
+}
+
+func (j *jsonWriter) Write(row Row) error {
+	if j.keys == nil {
+		for _, name := range columnNames(j.columns, len(row)) {
+			key, err := json.Marshal(name)
+			if err != nil {
+				return fmt.Errorf("err dumping JSON: %w", err)
+			}
+			j.keys = append(j.keys, key)
+		}
+	}
+
+	_ = j.w.WriteByte('{')
+	for i, field := range row {
+		if i > 0 {
+			_ = j.w.WriteByte(',')
+		}
+		if i < len(j.keys) {
+			_, _ = j.w.Write(j.keys[i])
+		} else {
+			// Rows longer than the column list keep their extra values.
+			_, _ = j.w.WriteString(strconv.Quote(ColumnName(i)))
+		}
+		_ = j.w.WriteByte(':')
+
+		if field.Null {
+			_, _ = j.w.WriteString("null")
+			continue
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return fmt.Errorf("err dumping JSON: %w", err)
+		}
+		_, _ = j.w.Write(value)
+	}
+	_, err := j.w.WriteString("}\n")
+
+	return err
+}
+
+func (j *jsonWriter) Flush() error { return j.w.Flush() }
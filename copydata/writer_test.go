@@ -0,0 +1,97 @@
+package copydata_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+)
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	rows := []copydata.Row{
+		{v("1"), v("plain"), null},
+		{v("2"), v(""), v("a,\"b\"\nc")},
+	}
+
+	testCases := []struct {
+		desc    string
+		format  string
+		columns []string
+		want    string
+	}{
+		{
+			desc:    "csv",
+			format:  copydata.FormatCSV,
+			columns: []string{"id", "name", "note"},
+			want:    "id,name,note\r\n1,plain,\r\n2,\"\",\"a,\"\"b\"\"\nc\"\r\n",
+		},
+		{
+			desc:   "csv without columns",
+			format: copydata.FormatCSV,
+			want:   "column1,column2,column3\r\n1,plain,\r\n2,\"\",\"a,\"\"b\"\"\nc\"\r\n",
+		},
+		{
+			desc:    "ndjson",
+			format:  copydata.FormatNDJSON,
+			columns: []string{"id", "name", "note"},
+			want:    "{\"id\":\"1\",\"name\":\"plain\",\"note\":null}\n{\"id\":\"2\",\"name\":\"\",\"note\":\"a,\\\"b\\\"\\nc\"}\n",
+		},
+		{
+			desc:    "ndjson with extra values",
+			format:  copydata.FormatNDJSON,
+			columns: []string{"id"},
+			want:    "{\"id\":\"1\",\"column2\":\"plain\",\"column3\":null}\n{\"id\":\"2\",\"column2\":\"\",\"column3\":\"a,\\\"b\\\"\\nc\"}\n",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			w, err := copydata.NewWriter(&buf, tC.format, tC.columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range rows {
+				if err := w.Write(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if buf.String() != tC.want {
+				t.Errorf("expected=%q, got=%q", tC.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	if _, err := copydata.NewWriter(&bytes.Buffer{}, "xml", nil); !errors.Is(err, copydata.ErrUnknownOutputFormat) {
+		t.Errorf("expected=%v, got=%v", copydata.ErrUnknownOutputFormat, err)
+	}
+}
+
+func TestCSVWriterHeaderOnly(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	w, err := copydata.NewWriter(&buf, copydata.FormatCSV, []string{"id", "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "id,name\r\n" {
+		t.Errorf("expected=%q, got=%q", "id,name\r\n", buf.String())
+	}
+}
@@ -0,0 +1,165 @@
+// Package exporter writes dump metadata as a node_exporter textfile collector file.
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+)
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Cfg holds the config for the exporter.
+type Cfg struct {
+	// Output is the path of the .prom file to write.
+	Output string
+	// Dirs are the backup directories to scan.
+	Dirs []string
+}
+
+// Validate ensures that Cfg struct is valid.
+func (c *Cfg) Validate() error {
+	if len(c.Dirs) == 0 {
+		return fmt.Errorf("%w: no directories specified", ErrInvalidConfig)
+	}
+
+	if c.Output == "" {
+		return fmt.Errorf("%w: output not specified", ErrInvalidConfig)
+	}
+
+	if filepath.Ext(c.Output) != ".prom" {
+		return fmt.Errorf("%w: output must have a .prom extension", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+type gauge struct {
+	value func(d *extractor.Dump) float64
+	// known reports whether d records the value, if it may not.
+	known func(d *extractor.Dump) bool
+	name  string
+	help  string
+}
+
+var gauges = [...]gauge{
+	{
+		name:  "pgdump_created_timestamp_seconds",
+		help:  "Creation time of the newest dump, in seconds since the epoch.",
+		value: func(d *extractor.Dump) float64 { return float64(d.Metadata.CreatedAt().Unix()) },
+		known: func(d *extractor.Dump) bool { return d.Metadata.HasCreatedAt() },
+	},
+	{
+		name:  "pgdump_toc_entries",
+		help:  "Number of TOC entries in the newest dump.",
+		value: func(d *extractor.Dump) float64 { return float64(d.Metadata.TOCCount) },
+	},
+	{
+		name:  "pgdump_size_bytes",
+		help:  "Size of the newest dump in bytes.",
+		value: func(d *extractor.Dump) float64 { return float64(d.Size) },
+	},
+	{
+		name:  "pgdump_archive_version",
+		help:  "Archive format version of the newest dump, as (major << 16) | (minor << 8) | rev.",
+		value: func(d *extractor.Dump) float64 { return float64(d.Metadata.ArchiveVersion()) },
+	},
+}
+
+// Run scans cfg.Dirs and atomically replaces cfg.Output with gauges for the
+// newest dump of each database.
+func Run(ctx context.Context, cfg *Cfg) error {
+	var dumps []extractor.Dump
+	unreadable := 0
+
+	for _, dir := range cfg.Dirs {
+		found, err := extractor.ScanDir(ctx, dir, extractor.SourceOptions{})
+		if err != nil {
+			return err
+		}
+
+		for i := range found {
+			if found[i].Err != nil {
+				unreadable++
+			}
+		}
+
+		dumps = append(dumps, found...)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(cfg.Output), ".pgdump-*.prom.tmp")
+	if err != nil {
+		return fmt.Errorf("err creating output: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err = Write(tmp, extractor.LatestByDatabase(dumps), unreadable); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("err writing output: %w", err)
+	}
+
+	// node_exporter runs as another user, so the file must be readable.
+	if err = os.Chmod(tmp.Name(), 0o644); err != nil { //nolint:gosec // metrics are meant to be world-readable
+		return fmt.Errorf("err writing output: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), cfg.Output); err != nil {
+		return fmt.Errorf("err writing output: %w", err)
+	}
+
+	return nil
+}
+
+// Write writes the gauges for dumps in the Prometheus text exposition format.
+func Write(w io.Writer, dumps []extractor.Dump, unreadable int) error {
+	var b strings.Builder
+
+	for _, g := range gauges {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for i := range dumps {
+			if g.known != nil && !g.known(&dumps[i]) {
+				continue
+			}
+			fmt.Fprintf(&b, "%s{%s} %s\n", g.name, labels(&dumps[i]), strconv.FormatFloat(g.value(&dumps[i]), 'f', -1, 64))
+		}
+	}
+
+	fmt.Fprintf(&b, "# HELP pgdump_unreadable_files Number of dumps that could not be parsed.\n")
+	fmt.Fprintf(&b, "# TYPE pgdump_unreadable_files gauge\n")
+	fmt.Fprintf(&b, "pgdump_unreadable_files %d\n", unreadable)
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("err writing output: %w", err)
+	}
+
+	return nil
+}
+
+func labels(d *extractor.Dump) string {
+	pgDumpVersion := ""
+	if d.Metadata.PGDumpVersion != nil {
+		pgDumpVersion = *d.Metadata.PGDumpVersion
+	}
+
+	return fmt.Sprintf(`database="%s",format="%s",pgdump_version="%s"`,
+		escapeLabel(d.Database()), escapeLabel(d.Metadata.Format), escapeLabel(pgDumpVersion))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
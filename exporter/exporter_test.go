@@ -0,0 +1,134 @@
+package exporter_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/exporter"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+)
+
+func TestCfgValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		err    error
+		desc   string
+		config exporter.Cfg
+	}{
+		{
+			desc:   "no directories",
+			config: exporter.Cfg{Output: "pgdump.prom"},
+			err:    exporter.ErrInvalidConfig,
+		},
+		{
+			desc:   "no output",
+			config: exporter.Cfg{Dirs: []string{"/backups"}},
+			err:    exporter.ErrInvalidConfig,
+		},
+		{
+			desc:   "wrong extension",
+			config: exporter.Cfg{Dirs: []string{"/backups"}, Output: "pgdump.txt"},
+			err:    exporter.ErrInvalidConfig,
+		},
+		{
+			desc:   "valid",
+			config: exporter.Cfg{Dirs: []string{"/backups"}, Output: "pgdump.prom"},
+			err:    nil,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res := tC.config.Validate()
+			if !errors.Is(res, tC.err) {
+				t.Errorf("expected=%v, got=%v", tC.err, res)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	created := time.Unix(1622742813, 0).In(time.Local)
+	backups := t.TempDir()
+	files := map[string][]byte{
+		"app-1.dump":  (&dumptest.Archive{Database: "app", Created: created.Add(-24 * time.Hour), TOCCount: 3, PGDumpVersion: "16.1"}).Bytes(),
+		"app-2.dump":  (&dumptest.Archive{Database: "app", Created: created, TOCCount: 15, PGDumpVersion: "16.1"}).Bytes(),
+		"broken.dump": []byte("PGDMP"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(backups, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output := filepath.Join(t.TempDir(), "pgdump.prom")
+	cfg := exporter.Cfg{Dirs: []string{backups}, Output: output}
+	if err := exporter.Run(context.Background(), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := `{database="app",format="CUSTOM",pgdump_version="16.1"}`
+	for _, exp := range []string{
+		"# TYPE pgdump_created_timestamp_seconds gauge\n",
+		"pgdump_created_timestamp_seconds" + labels + " 1622742813\n",
+		"pgdump_toc_entries" + labels + " 15\n",
+		"pgdump_size_bytes" + labels + " " + strconv.Itoa(len(files["app-2.dump"])) + "\n",
+		"pgdump_archive_version" + labels + " 68864\n",
+		"pgdump_unreadable_files 1\n",
+	} {
+		if !strings.Contains(string(res), exp) {
+			t.Errorf("expected output to contain %q, got=%s", exp, res)
+		}
+	}
+
+	if strings.Count(string(res), "pgdump_toc_entries{") != 1 {
+		t.Errorf("expected only the newest dump to be exported, got=%s", res)
+	}
+}
+
+func TestWriteBeforeCreationTime(t *testing.T) {
+	t.Parallel()
+
+	// Archives before version 1.4 record no creation time or database.
+	backups := t.TempDir()
+	data := (&dumptest.Archive{VMin: 3, TOCCount: 7}).Bytes()
+	if err := os.WriteFile(filepath.Join(backups, "legacy.dump"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "pgdump.prom")
+	cfg := exporter.Cfg{Dirs: []string{backups}, Output: output}
+	if err := exporter.Run(context.Background(), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(res), "pgdump_created_timestamp_seconds{") {
+		t.Errorf("expected no creation time sample, got=%s", res)
+	}
+	if !strings.Contains(string(res), "# TYPE pgdump_created_timestamp_seconds gauge\n") {
+		t.Errorf("expected the creation time gauge to be declared, got=%s", res)
+	}
+	if !strings.Contains(string(res), `pgdump_toc_entries{database="",format="CUSTOM",pgdump_version=""} 7`) {
+		t.Errorf("expected the TOC entries sample, got=%s", res)
+	}
+}
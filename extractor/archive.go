@@ -0,0 +1,87 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+)
+
+var ErrNotRandomAccess = errors.New("source does not support random access")
+
+// RandomAccess returns src as an io.ReaderAt, or ErrNotRandomAccess for
+// sources such as stdin that can only be read sequentially.
+func RandomAccess(src Source) (io.ReaderAt, error) {
+	at, ok := src.(io.ReaderAt)
+	if tarSrc, isTar := src.(*TarSource); isTar && tarSrc.at == nil {
+		// The member of a compressed tar can only be read in sequence.
+		ok = false
+	}
+	if !ok || src.Size() < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotRandomAccess, src.Name())
+	}
+
+	return at, nil
+}
+
+// OpenArchive parses the header and TOC of src for random access. Local
+// files pick up any sidecar offset index written next to them, unless it was
+// written for another archive, in which case the TOC offsets are kept.
+func OpenArchive(src Source) (*archive.Archive, error) {
+	at, err := RandomAccess(src)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := archive.Open(at, src.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	if file, ok := src.(*FileSource); ok {
+		err := a.ApplySidecar(file.Name())
+		if err != nil && !errors.Is(err, archive.ErrIndexMismatch) {
+			return nil, err
+		}
+	}
+
+	return a, nil
+}
+
+// OpenReader returns a sequential reader over the data blocks of src, using
+// random access to parse the header and TOC when src supports it. Only custom
+// format archives can be read this way.
+func OpenReader(src Source) (*archive.Reader, error) {
+	a, err := OpenArchive(src)
+	if errors.Is(err, ErrNotRandomAccess) {
+		return archive.NewReader(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Walk()
+}
+
+// OpenDir parses the header and TOC of a dump that keeps its data in separate
+// files, for access to them: a directory format dump, either unpacked or in an
+// uncompressed tar, or a FILE format archive with its data files beside it.
+func OpenDir(src Source) (*archive.Dir, error) {
+	switch s := src.(type) {
+	case *DirSource:
+		return archive.OpenDir(os.DirFS(s.dir))
+	case *TarSource:
+		fsys, err := s.FS()
+		if err != nil {
+			return nil, err
+		}
+		return archive.OpenDir(fsys)
+	case *FileSource:
+		return archive.OpenFiles(os.DirFS(filepath.Dir(s.Name())), filepath.Base(s.Name()))
+	}
+
+	return nil, fmt.Errorf("%w: %s does not keep data in separate files", archive.ErrUnsupportedFormat, src.Name())
+}
@@ -0,0 +1,46 @@
+package extractor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+func TestOpenReaderUnsupportedFormat(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "toc.dat"), dirDump(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := extractor.OpenReader(mustOpen(t, dir)); !errors.Is(err, archive.ErrUnsupportedFormat) {
+		t.Errorf("expected=%v, got=%v", archive.ErrUnsupportedFormat, err)
+	}
+}
+
+func TestOpenArchiveStaleSidecar(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "shop.dump")
+	if err := os.WriteFile(path, tablesArchive(true).Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stale := &archive.Index{Size: 1, Entries: []archive.IndexEntry{{DumpID: 2, Offset: 1}}}
+	if err := archive.WriteIndex(path+archive.IndexSuffix, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := extractor.OpenArchive(mustOpen(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Entry(2).DataState; got != metadata.OffsetPosNotSet {
+		t.Errorf("expected=%d, got=%d", metadata.OffsetPosNotSet, got)
+	}
+}
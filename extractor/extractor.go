@@ -1,19 +1,28 @@
 package extractor
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/mble/pgdump-metadata-extractor/metadata"
+	"github.com/mble/pgdump-metadata-extractor/s3"
 )
 
 var ErrInvalidConfig = errors.New("invalid config")
 
 // Cfg holds the config for the extractor.
 type Cfg struct {
-	FileName string
-	Stdin    bool
+	FileName    string
+	Stdin       bool
+	Xattrs      bool
+	WriteXattrs bool
+	S3Endpoint  string
+	S3Region    string
+	Hash        bool
+	Lenient     bool
 }
 
 // Validate ensures that Cfg struct is valid.
@@ -26,16 +35,69 @@ func (c *Cfg) Validate() error {
 		return fmt.Errorf("%w: can't provide file and read from stdin", ErrInvalidConfig)
 	}
 
+	if (c.Xattrs || c.WriteXattrs) && (c.FileName == "" || IsURL(c.FileName) || s3.IsURL(c.FileName) || IsParts(c.FileName)) {
+		return fmt.Errorf("%w: extended attributes require a local file", ErrInvalidConfig)
+	}
+
+	if c.Hash && c.Xattrs {
+		return fmt.Errorf("%w: hashing reads the whole file, can't serve from extended attributes", ErrInvalidConfig)
+	}
+
+	if c.Lenient && (c.Hash || c.Xattrs || c.WriteXattrs) {
+		return fmt.Errorf("%w: lenient mode reports partial results, which aren't hashed or cached", ErrInvalidConfig)
+	}
+
+	if (c.Hash || c.Lenient) && s3.IsPrefixURL(c.FileName) {
+		return fmt.Errorf("%w: only the header of each dump under an S3 prefix is read, so it can't be hashed or read leniently", ErrInvalidConfig)
+	}
+
 	return nil
 }
 
-// Run attempts to read metadata from fd byte-by-byte,
-// returning JSON or an error.
-func Run(fd io.Reader) ([]byte, error) {
+// SourceOptions returns the options for opening the configured source.
+func (c *Cfg) SourceOptions() SourceOptions {
+	return SourceOptions{S3Endpoint: c.S3Endpoint, S3Region: c.S3Region}
+}
+
+// IsURL reports whether name refers to a dump served over HTTP(S).
+func IsURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+// Extract attempts to read metadata from fd byte-by-byte.
+func Extract(fd io.Reader) (metadata.Metadata, error) {
 	data, err := metadata.NewMetadata(fd)
 	if err != nil {
 		err = fmt.Errorf("err reading metadata: %w", err)
 
+		return data, err
+	}
+
+	return data, nil
+}
+
+// ExtractTOC reads the metadata and every TOC entry from fd.
+func ExtractTOC(fd io.Reader) (metadata.Metadata, []metadata.TOCEntry, error) {
+	br := bufio.NewReader(fd)
+
+	data, err := Extract(br)
+	if err != nil {
+		return data, nil, err
+	}
+
+	entries, err := data.ReadTOC(br)
+	if err != nil {
+		return data, entries, fmt.Errorf("err reading TOC: %w", err)
+	}
+
+	return data, entries, nil
+}
+
+// Run attempts to read metadata from fd byte-by-byte,
+// returning JSON or an error.
+func Run(fd io.Reader) ([]byte, error) {
+	data, err := Extract(fd)
+	if err != nil {
 		return nil, err
 	}
 
@@ -1,11 +1,13 @@
 package extractor_test
 
 import (
+	"bytes"
 	"errors"
 	"os"
 	"testing"
 
 	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
 	"github.com/mble/pgdump-metadata-extractor/metadata"
 )
 
@@ -41,6 +43,55 @@ func TestCfgValidate(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			desc: "xattrs with stdin",
+			config: extractor.Cfg{
+				Stdin:  true,
+				Xattrs: true,
+			},
+			err: extractor.ErrInvalidConfig,
+		},
+		{
+			desc: "xattrs with URL",
+			config: extractor.Cfg{
+				FileName:    "https://backups.internal/latest.dump",
+				WriteXattrs: true,
+			},
+			err: extractor.ErrInvalidConfig,
+		},
+		{
+			desc: "lenient with hash",
+			config: extractor.Cfg{
+				Stdin:   true,
+				Lenient: true,
+				Hash:    true,
+			},
+			err: extractor.ErrInvalidConfig,
+		},
+		{
+			desc: "hash with S3 prefix",
+			config: extractor.Cfg{
+				FileName: "s3://backups/nightly/",
+				Hash:     true,
+			},
+			err: extractor.ErrInvalidConfig,
+		},
+		{
+			desc: "lenient with S3 prefix",
+			config: extractor.Cfg{
+				FileName: "s3://backups",
+				Lenient:  true,
+			},
+			err: extractor.ErrInvalidConfig,
+		},
+		{
+			desc: "hash with S3 object",
+			config: extractor.Cfg{
+				FileName: "s3://backups/nightly/latest.dump",
+				Hash:     true,
+			},
+			err: nil,
+		},
 		{
 			desc: "stdin and no filename",
 			config: extractor.Cfg{
@@ -130,3 +181,22 @@ func TestRunFileErr(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractTOC(t *testing.T) {
+	t.Parallel()
+
+	built := dumptest.Archive{
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop"},
+			{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", Data: []byte("1\n")},
+		},
+	}
+
+	meta, entries, err := extractor.ExtractTOC(bytes.NewReader(built.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TOCCount != 2 || len(entries) != 2 || entries[1].Desc != "TABLE DATA" {
+		t.Errorf("unexpected TOC: %+v", entries)
+	}
+}
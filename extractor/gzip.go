@@ -0,0 +1,63 @@
+package extractor
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// GzipSource decompresses a gzipped source, such as a tar.gz of a directory
+// format dump. It can only be read sequentially.
+type GzipSource struct {
+	*gzip.Reader
+	base Source
+}
+
+// NewGzipSource reads the gzip header of base.
+func NewGzipSource(base Source) (*GzipSource, error) {
+	zr, err := gzip.NewReader(base)
+	if err != nil {
+		return nil, fmt.Errorf("err decompressing %s: %w", base.Name(), err)
+	}
+
+	return &GzipSource{Reader: zr, base: base}, nil
+}
+
+// Close closes the underlying source.
+func (g *GzipSource) Close() error { return g.base.Close() }
+
+// Name names the underlying source.
+func (g *GzipSource) Name() string { return g.base.Name() }
+
+// Size returns the compressed size of the underlying source.
+func (g *GzipSource) Size() int64 { return g.base.Size() }
+
+// Stat describes the underlying file, when there is one.
+func (g *GzipSource) Stat() (fs.FileInfo, error) { return statSource(g.base) }
+
+// peekSource buffers a sequential source so that its first bytes can be
+// looked at before it is read.
+type peekSource struct {
+	Source
+	br *bufio.Reader
+}
+
+func newPeekSource(src Source) *peekSource {
+	return &peekSource{Source: src, br: bufio.NewReader(src)}
+}
+
+func (p *peekSource) Read(b []byte) (int, error) { return p.br.Read(b) }
+
+// Stat describes the underlying file, when there is one.
+func (p *peekSource) Stat() (fs.FileInfo, error) { return statSource(p.Source) }
+
+// statSource stats src when it is backed by the local filesystem.
+func statSource(src Source) (fs.FileInfo, error) {
+	if st, ok := src.(StatSource); ok {
+		return st.Stat()
+	}
+
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,85 @@
+package extractor
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Digests holds checksums of the whole input.
+type Digests struct {
+	// SHA256 is the hex-encoded SHA-256 of the input.
+	SHA256 string `json:"sha256"`
+	// SHA512 is the hex-encoded SHA-512 of the input.
+	SHA512 string `json:"sha512"`
+	// CRC32C is the hex-encoded CRC-32 (Castagnoli) of the input.
+	CRC32C string `json:"crc32c"`
+	// Size is the number of bytes hashed.
+	Size int64 `json:"size"`
+}
+
+// HashedMetadata is the metadata of a dump along with digests of the whole dump.
+type HashedMetadata struct {
+	metadata.Metadata
+	Hashes Digests `json:"hashes"`
+}
+
+// ToJSON returns a JSON representation of the metadata and digests.
+func (h *HashedMetadata) ToJSON() ([]byte, error) {
+	out, err := json.Marshal(h)
+	if err != nil {
+		err = fmt.Errorf("err dumping JSON: %w", err)
+
+		return []byte{}, err
+	}
+
+	return out, nil
+}
+
+// ExtractHashed reads metadata from fd and then continues to the end of the
+// input, hashing every byte in the same streaming pass.
+func ExtractHashed(fd io.Reader) (HashedMetadata, error) {
+	sha256Hash := sha256.New()
+	sha512Hash := sha512.New()
+	crc32cHash := crc32.New(crc32cTable)
+	counter := &countingWriter{}
+
+	tee := io.TeeReader(fd, io.MultiWriter(sha256Hash, sha512Hash, crc32cHash, counter))
+
+	data, err := Extract(tee)
+	if err != nil {
+		return HashedMetadata{Metadata: data}, err
+	}
+
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return HashedMetadata{Metadata: data}, fmt.Errorf("err hashing input: %w", err)
+	}
+
+	return HashedMetadata{
+		Metadata: data,
+		Hashes: Digests{
+			SHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
+			SHA512: hex.EncodeToString(sha512Hash.Sum(nil)),
+			CRC32C: hex.EncodeToString(crc32cHash.Sum(nil)),
+			Size:   counter.n,
+		},
+	}, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+
+	return len(p), nil
+}
@@ -0,0 +1,71 @@
+package extractor_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+func TestExtractHashed(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("../testdata/min.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trailing bytes past the header must still be hashed.
+	data = append(data, bytes.Repeat([]byte{0xab}, 64<<10)...)
+	sum := sha256.Sum256(data)
+
+	res, err := extractor.ExtractHashed(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := hex.EncodeToString(sum[:]); res.Hashes.SHA256 != exp {
+		t.Errorf("expected=%s, got=%s", exp, res.Hashes.SHA256)
+	}
+	if res.Hashes.Size != int64(len(data)) {
+		t.Errorf("expected=%d, got=%d", len(data), res.Hashes.Size)
+	}
+	if res.TOCCount != 15 {
+		t.Errorf("expected=%d, got=%d", 15, res.TOCCount)
+	}
+}
+
+func TestExtractHashedCRC32C(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile("../testdata/min.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := extractor.ExtractHashed(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := fmt.Sprintf("%08x", crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)))
+	if res.Hashes.CRC32C != exp {
+		t.Errorf("expected=%s, got=%s", exp, res.Hashes.CRC32C)
+	}
+}
+
+func TestExtractHashedNotADump(t *testing.T) {
+	t.Parallel()
+
+	_, err := extractor.ExtractHashed(bytes.NewReader([]byte("not a dump at all")))
+	if !errors.Is(err, metadata.ErrNotADump) {
+		t.Errorf("expected=%v, got=%v", metadata.ErrNotADump, err)
+	}
+}
@@ -0,0 +1,256 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrPartsNotContiguous = errors.New("split parts are not contiguous")
+var ErrNoParts = errors.New("no split parts found")
+
+// partsSeparator separates the paths of an explicit list of parts.
+const partsSeparator = ","
+
+// IsParts reports whether name lists split parts, either as a glob pattern
+// such as "db.dump.*" or as a comma-separated list. A file that exists under
+// that name is not parts, whatever characters its name has.
+func IsParts(name string) bool {
+	if _, err := os.Stat(name); err == nil {
+		return false
+	}
+
+	return strings.ContainsAny(name, "*?[") || strings.Contains(name, partsSeparator)
+}
+
+// ExpandParts returns the paths of the parts named by name, in order: the
+// sorted matches of a glob pattern, or the paths of a list as given. The
+// matches of a glob pattern must all be named with split's suffixes, so that
+// other files matching it, such as a checksum, aren't taken for parts.
+func ExpandParts(name string) ([]string, error) {
+	if strings.Contains(name, partsSeparator) {
+		return strings.Split(name, partsSeparator), nil
+	}
+
+	paths, err := filepath.Glob(name)
+	if err != nil {
+		return nil, fmt.Errorf("err expanding %s: %w", name, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoParts, name)
+	}
+	sort.Strings(paths)
+
+	if _, ok := suffixLength(paths); len(paths) > 1 && !ok {
+		return nil, fmt.Errorf("%w: %s matches files not named as split parts", ErrPartsNotContiguous, name)
+	}
+
+	return paths, nil
+}
+
+// PartsSource reads a dump split into several files, such as the output of
+// split -b, as one contiguous stream.
+type PartsSource struct {
+	parts []*FileSource
+	// starts holds the offset of the first byte of each part.
+	starts []int64
+	name   string
+	size   int64
+	pos    int64
+}
+
+// NewPartsSource opens the parts at paths, in order, checking that they
+// form a complete sequence: named without gaps when they follow split's
+// suffix naming, and all but the last of the same size.
+func NewPartsSource(name string, paths []string) (*PartsSource, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoParts, name)
+	}
+	if err := checkPartNames(paths); err != nil {
+		return nil, err
+	}
+
+	src := &PartsSource{name: name}
+	for _, path := range paths {
+		part, err := NewFileSource(path)
+		if err != nil {
+			_ = src.Close()
+			return nil, err
+		}
+
+		src.parts = append(src.parts, part)
+		src.starts = append(src.starts, src.size)
+		src.size += part.Size()
+	}
+
+	if err := src.checkSizes(); err != nil {
+		_ = src.Close()
+		return nil, err
+	}
+
+	return src, nil
+}
+
+// checkSizes checks that every part but the last is the same size, as a
+// split into fixed-size pieces would produce, and that none is empty.
+func (s *PartsSource) checkSizes() error {
+	want := s.parts[0].Size()
+	for i, part := range s.parts {
+		switch {
+		case part.Size() == 0:
+			return fmt.Errorf("%w: %s is empty", ErrPartsNotContiguous, part.Name())
+		case i < len(s.parts)-1 && part.Size() != want:
+			return fmt.Errorf("%w: %s is %d bytes, expected=%d", ErrPartsNotContiguous, part.Name(), part.Size(), want)
+		}
+	}
+
+	return nil
+}
+
+// checkPartNames checks that parts named with split's suffixes, such as
+// .aa, .ab, … or .00, .01, …, start at the first suffix and have no gaps.
+// Parts named otherwise are taken in the order given.
+func checkPartNames(paths []string) error {
+	n, ok := suffixLength(paths)
+	if !ok || len(paths) < 2 {
+		return nil
+	}
+
+	prev := -1
+	for i, path := range paths {
+		val, _ := suffixValue(path[len(path)-n:])
+		if prev < 0 && val != 0 {
+			return fmt.Errorf("%w: %s is not the first part", ErrPartsNotContiguous, path)
+		}
+		if prev >= 0 && val != prev+1 {
+			return fmt.Errorf("%w: %s does not follow %s", ErrPartsNotContiguous, path, paths[i-1])
+		}
+		prev = val
+	}
+
+	return nil
+}
+
+// suffixLength returns the length of the suffixes of paths named as split
+// names its parts: all the same length, differing only in an alphabetic or
+// numeric suffix of at least two characters.
+func suffixLength(paths []string) (int, bool) {
+	prefix := paths[0]
+	for _, path := range paths[1:] {
+		if len(path) != len(paths[0]) {
+			return 0, false
+		}
+		for !strings.HasPrefix(path, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	// Suffixes are at least two characters long, however many the parts
+	// have in common.
+	n := max(len(paths[0])-len(prefix), min(2, len(paths[0])))
+
+	for _, path := range paths {
+		if _, ok := suffixValue(path[len(path)-n:]); !ok {
+			return 0, false
+		}
+	}
+
+	return n, true
+}
+
+// suffixValue returns the position in split's sequence of an alphabetic or
+// numeric suffix. split -d numbers parts from 00, as its alphabetic suffixes
+// start at aa, so the first part is at position 0 either way.
+func suffixValue(suffix string) (val int, ok bool) {
+	alpha, digits := true, true
+	for _, c := range suffix {
+		alpha = alpha && c >= 'a' && c <= 'z'
+		digits = digits && c >= '0' && c <= '9'
+	}
+
+	for _, c := range suffix {
+		switch {
+		case alpha:
+			val = val*26 + int(c-'a')
+		case digits:
+			val = val*10 + int(c-'0')
+		default:
+			return 0, false
+		}
+	}
+
+	return val, true
+}
+
+// Read reads the parts in sequence.
+func (s *PartsSource) Read(p []byte) (int, error) {
+	n, err := s.ReadAt(p, s.pos)
+	s.pos += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+
+	return n, err
+}
+
+// ReadAt reads across part boundaries as needed.
+func (s *PartsSource) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("%w: negative offset", os.ErrInvalid)
+	}
+	if off >= s.size {
+		return 0, io.EOF
+	}
+
+	i := sort.Search(len(s.starts), func(i int) bool { return s.starts[i] > off }) - 1
+	read := 0
+	for ; read < len(p) && i < len(s.parts); i++ {
+		part := s.parts[i]
+		partOff := off + int64(read) - s.starts[i]
+		want := int(min(int64(len(p)-read), part.Size()-partOff))
+
+		n, err := part.ReadAt(p[read:read+want], partOff)
+		read += n
+		if err != nil && !errors.Is(err, io.EOF) {
+			return read, err
+		}
+		if n < want {
+			return read, fmt.Errorf("%w: %s shrank while being read", io.ErrUnexpectedEOF, part.Name())
+		}
+	}
+
+	if read < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+// Close closes every part.
+func (s *PartsSource) Close() error {
+	var errs []error
+	for _, part := range s.parts {
+		errs = append(errs, part.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// Name returns the pattern or list the parts were named by.
+func (s *PartsSource) Name() string { return s.name }
+
+// Size returns the combined size of the parts.
+func (s *PartsSource) Size() int64 { return s.size }
+
+// Parts returns the paths of the parts, in order.
+func (s *PartsSource) Parts() []string {
+	paths := make([]string, len(s.parts))
+	for i, part := range s.parts {
+		paths[i] = part.Name()
+	}
+
+	return paths
+}
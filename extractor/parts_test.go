@@ -0,0 +1,184 @@
+package extractor_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+)
+
+func splitDump(t *testing.T, data []byte, size int, suffixes ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for i, suffix := range suffixes {
+		part := data[min(i*size, len(data)):min((i+1)*size, len(data))]
+		if err := os.WriteFile(filepath.Join(dir, "db.dump."+suffix), part, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return filepath.Join(dir, "db.dump.")
+}
+
+func TestOpenSourceParts(t *testing.T) {
+	t.Parallel()
+
+	built := &dumptest.Archive{
+		Database: "shop",
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", Data: []byte(strings.Repeat("1\n", 100))},
+		},
+	}
+	data := built.Bytes()
+	size := len(data)/3 + 1
+	prefix := splitDump(t, data, size, "aa", "ab", "ac")
+
+	testCases := []struct {
+		desc string
+		name string
+	}{
+		{desc: "glob", name: prefix + "*"},
+		{desc: "list", name: prefix + "aa," + prefix + "ab," + prefix + "ac"},
+	}
+	for _, tC := range testCases {
+		src, err := extractor.OpenSource(context.Background(), tC.name, extractor.SourceOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", tC.desc, err)
+		}
+		defer src.Close()
+
+		if src.Size() != int64(len(data)) {
+			t.Errorf("%s: expected=%d, got=%d", tC.desc, len(data), src.Size())
+		}
+
+		a, err := extractor.OpenArchive(src)
+		if err != nil {
+			t.Fatalf("%s: %v", tC.desc, err)
+		}
+		rc, err := a.Data(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != strings.Repeat("1\n", 100) {
+			t.Errorf("%s: unexpected data: %q", tC.desc, got)
+		}
+
+		all, err := io.ReadAll(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(all, data) {
+			t.Errorf("%s: sequential read differs from the original", tC.desc)
+		}
+	}
+}
+
+func TestPartsReadAt(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("0123456789abcdefghij")
+	prefix := splitDump(t, data, 7, "00", "01", "02")
+
+	src, err := extractor.NewPartsSource("parts", []string{prefix + "00", prefix + "01", prefix + "02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	testCases := []struct {
+		off int64
+		n   int
+		err error
+	}{
+		{off: 0, n: 20},
+		{off: 5, n: 10},
+		{off: 13, n: 7},
+		{off: 15, n: 10, err: io.EOF},
+		{off: 20, n: 1, err: io.EOF},
+	}
+	for _, tC := range testCases {
+		buf := make([]byte, tC.n)
+		n, err := src.ReadAt(buf, tC.off)
+		if !errors.Is(err, tC.err) {
+			t.Errorf("off=%d: expected=%v, got=%v", tC.off, tC.err, err)
+		}
+		if want := data[min(tC.off, 20):min(tC.off+int64(tC.n), 20)]; !bytes.Equal(buf[:n], want) {
+			t.Errorf("off=%d: expected=%q, got=%q", tC.off, want, buf[:n])
+		}
+	}
+}
+
+func TestIsParts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup[1].dump")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		desc     string
+		name     string
+		expected bool
+	}{
+		{desc: "glob", name: filepath.Join(dir, "db.dump.*"), expected: true},
+		{desc: "list", name: "db.dump.aa,db.dump.ab", expected: true},
+		{desc: "file", name: filepath.Join(dir, "db.dump"), expected: false},
+		{desc: "file named like a glob", name: file, expected: false},
+	}
+	for _, tC := range testCases {
+		if got := extractor.IsParts(tC.name); got != tC.expected {
+			t.Errorf("%s: expected=%v, got=%v", tC.desc, tC.expected, got)
+		}
+	}
+
+	cfg := extractor.Cfg{FileName: file, Xattrs: true}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected not err, got=%v", err)
+	}
+}
+
+func TestPartsNotContiguous(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte{'x'}, 30)
+
+	testCases := []struct {
+		desc  string
+		parts []string
+		sizes int
+	}{
+		{desc: "gap", parts: []string{"aa", "ab", "ad"}, sizes: 10},
+		{desc: "missing first", parts: []string{"ab", "ac"}, sizes: 15},
+		{desc: "missing first numeric", parts: []string{"01", "02"}, sizes: 15},
+		{desc: "short part", parts: []string{"aa", "ab", "ac", "ad"}, sizes: 9},
+		{desc: "checksum alongside", parts: []string{"aa", "ab", "sha256"}, sizes: 15},
+	}
+	for _, tC := range testCases {
+		prefix := splitDump(t, data, tC.sizes, tC.parts...)
+		if tC.desc == "short part" {
+			// Truncate a middle part, as an interrupted copy would.
+			if err := os.Truncate(prefix+"ab", 5); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := extractor.OpenSource(context.Background(), prefix+"*", extractor.SourceOptions{})
+		if !errors.Is(err, extractor.ErrPartsNotContiguous) {
+			t.Errorf("%s: expected=%v, got=%v", tC.desc, extractor.ErrPartsNotContiguous, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+// Dump describes a dump read from a Source.
+type Dump struct {
+	ModTime  time.Time
+	Err      error
+	Path     string
+	Metadata metadata.Metadata
+	Size     int64
+}
+
+// Database returns the name of the database dumped, or an empty string when
+// it is not recorded.
+func (d *Dump) Database() string {
+	return derefString(d.Metadata.DatabaseName)
+}
+
+// ScanSource reads the metadata of the dump in src. Errors reading the dump
+// itself are recorded on the returned Dump.
+func ScanSource(src Source) Dump {
+	dump := Dump{Path: src.Name(), Size: src.Size()}
+
+	if st, ok := src.(StatSource); ok {
+		if info, err := st.Stat(); err == nil {
+			dump.ModTime = info.ModTime()
+		}
+	}
+
+	dump.Metadata, dump.Err = Extract(src)
+
+	return dump
+}
+
+// ScanFile reads the metadata of the dump named by name, which may be
+// anything OpenSource accepts. Errors reading the dump itself are recorded on
+// the returned Dump rather than returned.
+func ScanFile(ctx context.Context, name string, opts SourceOptions) (Dump, error) {
+	src, err := OpenSource(ctx, name, opts)
+	if err != nil {
+		return Dump{Path: name}, err
+	}
+	defer src.Close()
+
+	return ScanSource(src), nil
+}
+
+// ScanDir reads the metadata of every dump in dir, including directory and
+// tar format dumps. Files that are not dumps are skipped; files that can't be
+// opened or parsed are returned with Err set, so that one bad file doesn't
+// hide the others.
+func ScanDir(ctx context.Context, dir string, opts SourceOptions) ([]Dump, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("err reading directory: %w", err)
+	}
+
+	dumps := make([]Dump, 0, len(entries))
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.Type().IsRegular() && !(entry.IsDir() && IsDumpDir(path)) {
+			continue
+		}
+
+		dump, err := ScanFile(ctx, path, opts)
+		if errors.Is(err, ErrMemberNotFound) || errors.Is(dump.Err, metadata.ErrNotADump) {
+			continue
+		}
+		if err != nil {
+			dump.Err = err
+			if info, infoErr := entry.Info(); infoErr == nil {
+				dump.Size, dump.ModTime = info.Size(), info.ModTime()
+			}
+		}
+
+		dumps = append(dumps, dump)
+	}
+
+	return dumps, nil
+}
+
+// LatestByDatabase returns the most recently created readable dump of each
+// database, ordered by database name.
+func LatestByDatabase(dumps []Dump) []Dump {
+	latest := make(map[string]Dump)
+	for _, dump := range dumps {
+		if dump.Err != nil {
+			continue
+		}
+
+		current, ok := latest[dump.Database()]
+		if !ok || dump.Metadata.CreatedAt().After(current.Metadata.CreatedAt()) {
+			latest[dump.Database()] = dump
+		}
+	}
+
+	out := make([]Dump, 0, len(latest))
+	for _, dump := range latest {
+		out = append(out, dump)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Database() < out[j].Database()
+	})
+
+	return out
+}
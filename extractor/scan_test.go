@@ -0,0 +1,103 @@
+package extractor_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+)
+
+func TestScanDirLatestByDatabase(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2021, time.June, 3, 18, 0, 0, 0, time.Local)
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"b-old.dump": (&dumptest.Archive{Database: "b", Created: created}).Bytes(),
+		"b-new.dump": (&dumptest.Archive{Database: "b", Created: created.Add(time.Hour)}).Bytes(),
+		"a.dump":     (&dumptest.Archive{Database: "a", Created: created}).Bytes(),
+		"notes.txt":  []byte("definitely not a dump"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dumps, err := extractor.ScanDir(context.Background(), dir, extractor.SourceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 3 {
+		t.Fatalf("expected=%d, got=%d", 3, len(dumps))
+	}
+
+	latest := extractor.LatestByDatabase(dumps)
+	if len(latest) != 2 {
+		t.Fatalf("expected=%d, got=%d", 2, len(latest))
+	}
+	if latest[0].Database() != "a" {
+		t.Errorf("expected=%s, got=%s", "a", latest[0].Database())
+	}
+	if filepath.Base(latest[1].Path) != "b-new.dump" {
+		t.Errorf("expected=%s, got=%s", "b-new.dump", filepath.Base(latest[1].Path))
+	}
+}
+
+func TestScanDirUnreadable(t *testing.T) {
+	t.Parallel()
+
+	// A tar header with a bad checksum can't be opened.
+	badTar := make([]byte, 1024)
+	copy(badTar, "toc.dat")
+	copy(badTar[257:], "ustar\x0000")
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a.dump":   (&dumptest.Archive{Database: "a"}).Bytes(),
+		"bad.tar":  badTar,
+		"b.dump":   (&dumptest.Archive{Database: "b"}).Bytes(),
+		"c.dump":   (&dumptest.Archive{Database: "c"}).Bytes(),
+		"notes.md": []byte("not a dump"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unreadable := []string{"bad.tar"}
+	if os.Geteuid() != 0 {
+		// Permissions don't stop root from reading the file.
+		if err := os.Chmod(filepath.Join(dir, "c.dump"), 0); err != nil {
+			t.Fatal(err)
+		}
+		unreadable = append(unreadable, "c.dump")
+	}
+
+	dumps, err := extractor.ScanDir(context.Background(), dir, extractor.SourceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 4 {
+		t.Fatalf("expected=%d, got=%d", 4, len(dumps))
+	}
+
+	var failed []string
+	for _, dump := range dumps {
+		if dump.Err != nil {
+			failed = append(failed, filepath.Base(dump.Path))
+		}
+	}
+	if !slices.Equal(failed, unreadable) {
+		t.Errorf("expected=%v, got=%v", unreadable, failed)
+	}
+
+	if latest := extractor.LatestByDatabase(dumps); len(latest) != 4-len(unreadable) {
+		t.Errorf("expected=%d, got=%d", 4-len(unreadable), len(latest))
+	}
+}
@@ -0,0 +1,364 @@
+package extractor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+	"github.com/mble/pgdump-metadata-extractor/remote"
+	"github.com/mble/pgdump-metadata-extractor/s3"
+)
+
+var ErrMemberNotFound = errors.New("tar member not found")
+
+// tocFileName is the member holding the header and TOC of directory and tar
+// format dumps.
+const tocFileName = "toc.dat"
+
+// Source is an input a dump is read from. Sources that support random access
+// also implement io.ReaderAt, and sources backed by the local filesystem
+// implement StatSource.
+type Source interface {
+	io.Reader
+	io.Closer
+	// Name identifies the source in output and errors.
+	Name() string
+	// Size returns the total size of the dump in bytes, or -1 when unknown.
+	Size() int64
+}
+
+// StatSource is a Source backed by the local filesystem.
+type StatSource interface {
+	Source
+	Stat() (fs.FileInfo, error)
+}
+
+// SourceOptions configures how OpenSource resolves names.
+type SourceOptions struct {
+	// S3Endpoint overrides the S3 endpoint for s3:// names.
+	S3Endpoint string
+	// S3Region is the signing region for s3:// names.
+	S3Region string
+}
+
+// OpenSource resolves name to a Source: "-" is stdin, http(s):// and s3://
+// URLs are read with range requests, a directory is read as a directory
+// format dump, a tar file is read through its toc.dat, whether it holds a tar
+// format dump or a packed directory format dump, a glob pattern or
+// comma-separated list naming no single file is read as split parts, and
+// anything else is read as a file. Gzipped input is decompressed first.
+func OpenSource(ctx context.Context, name string, opts SourceOptions) (Source, error) {
+	switch {
+	case name == "-":
+		return sniffStream(NewStdinSource())
+	case s3.IsURL(name):
+		bucket, key, err := s3.ParseURL(name)
+		if err != nil {
+			return nil, err
+		}
+		client, err := s3.NewClientFromEnv(opts.S3Endpoint, opts.S3Region)
+		if err != nil {
+			return nil, err
+		}
+		r, err := client.Open(ctx, bucket, key)
+		if err != nil {
+			return nil, fmt.Errorf("err opening %s: %w", name, err)
+		}
+		return NewRemoteSource(name, r), nil
+	case IsURL(name):
+		r, err := remote.Open(ctx, name, remote.Options{})
+		if err != nil {
+			return nil, fmt.Errorf("err opening URL: %w", err)
+		}
+		return NewRemoteSource(name, r), nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil && IsParts(name) {
+		return openParts(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err opening file: %w", err)
+	}
+	if info.IsDir() {
+		return NewDirSource(name)
+	}
+
+	file, err := NewFileSource(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return sniff(file)
+}
+
+// openParts opens the split parts named by name as one source.
+func openParts(name string) (Source, error) {
+	paths, err := ExpandParts(name)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := NewPartsSource(name, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	return sniff(parts)
+}
+
+// sniff looks at the first bytes of src, returning the toc.dat member when
+// src is a tar file and decompressing it when it is gzipped. Random access
+// is kept for uncompressed sources that support it.
+func sniff(src Source) (Source, error) {
+	at, ok := src.(io.ReaderAt)
+	if !ok || src.Size() < 0 {
+		return sniffStream(src)
+	}
+
+	head := make([]byte, sniffSize)
+	n, err := at.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		_ = src.Close()
+		return nil, fmt.Errorf("err reading %s: %w", src.Name(), err)
+	}
+
+	return sniffHead(src, head[:n])
+}
+
+// sniffStream is sniff for sources that can only be read sequentially,
+// buffering the bytes looked at.
+func sniffStream(src Source) (Source, error) {
+	peek := newPeekSource(src)
+
+	head, err := peek.br.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		_ = src.Close()
+		return nil, fmt.Errorf("err reading %s: %w", src.Name(), err)
+	}
+
+	return sniffHead(peek, head)
+}
+
+func sniffHead(src Source, head []byte) (Source, error) {
+	switch {
+	case isGzip(head):
+		gz, err := NewGzipSource(src)
+		if err != nil {
+			_ = src.Close()
+			return nil, err
+		}
+		return sniffStream(gz)
+	case isTar(head):
+		tarSrc, err := NewTarSource(src, tocFileName)
+		if err != nil {
+			_ = src.Close()
+			return nil, err
+		}
+		return tarSrc, nil
+	}
+
+	return src, nil
+}
+
+// FileSource reads a dump from a local file.
+type FileSource struct {
+	file *os.File
+	name string
+	size int64
+}
+
+// NewFileSource opens the file at path.
+func NewFileSource(path string) (*FileSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("err opening file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("err stating file: %w", err)
+	}
+
+	return &FileSource{file: file, name: path, size: info.Size()}, nil
+}
+
+func (f *FileSource) Read(p []byte) (int, error)              { return f.file.Read(p) }
+func (f *FileSource) ReadAt(p []byte, off int64) (int, error) { return f.file.ReadAt(p, off) }
+func (f *FileSource) Close() error                            { return f.file.Close() }
+func (f *FileSource) Name() string                            { return f.name }
+func (f *FileSource) Size() int64                             { return f.size }
+func (f *FileSource) Stat() (fs.FileInfo, error)              { return f.file.Stat() }
+
+// StdinSource reads a dump from standard input.
+type StdinSource struct{}
+
+// NewStdinSource returns a Source reading from os.Stdin.
+func NewStdinSource() *StdinSource {
+	return &StdinSource{}
+}
+
+func (s *StdinSource) Read(p []byte) (int, error) { return os.Stdin.Read(p) }
+func (s *StdinSource) Close() error               { return nil }
+func (s *StdinSource) Name() string               { return "-" }
+func (s *StdinSource) Size() int64                { return -1 }
+
+// DirSource reads the header and TOC of a directory format dump from its
+// toc.dat.
+type DirSource struct {
+	*FileSource
+	dir  string
+	size int64
+}
+
+// NewDirSource opens the directory format dump at dir.
+func NewDirSource(dir string) (*DirSource, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("err reading directory: %w", err)
+	}
+
+	var size int64
+	for _, entry := range entries {
+		if info, infoErr := entry.Info(); infoErr == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+	}
+
+	toc, err := NewFileSource(filepath.Join(dir, tocFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DirSource{FileSource: toc, dir: dir, size: size}, nil
+}
+
+// Name returns the directory of the dump.
+func (d *DirSource) Name() string { return d.dir }
+
+// Size returns the total size of the files in the dump directory.
+func (d *DirSource) Size() int64 { return d.size }
+
+// Stat describes the directory of the dump.
+func (d *DirSource) Stat() (fs.FileInfo, error) { return os.Stat(d.dir) }
+
+// IsDumpDir reports whether dir is a directory format dump.
+func IsDumpDir(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, tocFileName))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// TarSource reads a single member of a tar stream, such as the toc.dat of a
+// tar format dump. When the stream is backed by an io.ReaderAt, the member
+// supports random access too.
+type TarSource struct {
+	io.Reader
+	base   Source
+	at     io.ReaderAt
+	member string
+	// spool holds the decompressed tar once FS has needed it.
+	spool *os.File
+}
+
+// NewTarSource positions the tar stream in base at the first member whose
+// base name is member, ignoring any leading path.
+func NewTarSource(base Source, member string) (*TarSource, error) {
+	counter := &metadata.CountingReader{R: base}
+	tr := tar.NewReader(counter)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: %s in %s", ErrMemberNotFound, member, base.Name())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("err reading tar: %w", err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg || filepath.Base(hdr.Name) != member {
+			continue
+		}
+
+		src := &TarSource{
+			Reader: tr,
+			base:   base,
+			member: hdr.Name,
+		}
+		if at, ok := base.(io.ReaderAt); ok {
+			src.at = io.NewSectionReader(at, counter.N, hdr.Size)
+		}
+
+		return src, nil
+	}
+}
+
+// ReadAt implements io.ReaderAt when the underlying stream supports it.
+func (t *TarSource) ReadAt(p []byte, off int64) (int, error) {
+	if t.at == nil {
+		return 0, errors.ErrUnsupported
+	}
+
+	return t.at.ReadAt(p, off)
+}
+
+// Stat describes the underlying tar file, when there is one.
+func (t *TarSource) Stat() (fs.FileInfo, error) {
+	if st, ok := t.base.(StatSource); ok {
+		return st.Stat()
+	}
+
+	return nil, errors.ErrUnsupported
+}
+
+// Close closes the tar stream and removes any spooled copy of it.
+func (t *TarSource) Close() error {
+	if t.spool != nil {
+		_ = t.spool.Close()
+		_ = os.Remove(t.spool.Name())
+	}
+
+	return t.base.Close()
+}
+
+func (t *TarSource) Name() string { return t.base.Name() }
+func (t *TarSource) Size() int64  { return t.base.Size() }
+
+// Member returns the full name of the member being read.
+func (t *TarSource) Member() string { return t.member }
+
+// RemoteSource reads a dump over HTTP range requests.
+type RemoteSource struct {
+	*io.SectionReader
+	name string
+}
+
+// NewRemoteSource wraps a remote reader, naming it name.
+func NewRemoteSource(name string, r *remote.Reader) *RemoteSource {
+	return &RemoteSource{SectionReader: io.NewSectionReader(r, 0, r.Size()), name: name}
+}
+
+func (r *RemoteSource) Close() error { return nil }
+func (r *RemoteSource) Name() string { return r.name }
+
+// sniffSize is how many leading bytes of a source are looked at to detect
+// its type: a whole tar header block.
+const sniffSize = 512
+
+// isTar reports whether head starts with a tar header block, by its ustar
+// magic.
+func isTar(head []byte) bool {
+	return len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar"))
+}
+
+// isGzip reports whether head starts with a gzip member header.
+func isGzip(head []byte) bool {
+	return len(head) >= 3 && head[0] == 0x1f && head[1] == 0x8b && head[2] == 8
+}
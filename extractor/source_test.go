@@ -0,0 +1,181 @@
+package extractor_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+)
+
+func readMinDump(t *testing.T) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile("../testdata/min.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func writeTar(t *testing.T, path string, members map[string][]byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, data := range members {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenSource(t *testing.T) {
+	t.Parallel()
+
+	dump := readMinDump(t)
+	dir := t.TempDir()
+
+	dumpDir := filepath.Join(dir, "db.dir")
+	if err := os.Mkdir(dumpDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dumpDir, "toc.dat"), dump, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dumpDir, "3000.dat.gz"), make([]byte, 100), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tarPath := filepath.Join(dir, "db.tar")
+	writeTar(t, tarPath, map[string][]byte{"toc.dat": dump})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "min.dump", time.Time{}, bytes.NewReader(dump))
+	}))
+	t.Cleanup(srv.Close)
+
+	testCases := []struct {
+		desc string
+		name string
+		size int64
+	}{
+		{desc: "file", name: "../testdata/min.dump", size: int64(len(dump))},
+		{desc: "directory", name: dumpDir, size: int64(len(dump)) + 100},
+		{desc: "tar", name: tarPath, size: 2048},
+		{desc: "http", name: srv.URL + "/min.dump", size: int64(len(dump))},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := extractor.OpenSource(context.Background(), tC.name, extractor.SourceOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.Close()
+
+			if src.Name() != tC.name {
+				t.Errorf("expected=%s, got=%s", tC.name, src.Name())
+			}
+			if src.Size() != tC.size {
+				t.Errorf("expected=%d, got=%d", tC.size, src.Size())
+			}
+
+			meta, err := extractor.Extract(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.TOCCount != 15 {
+				t.Errorf("expected=%d, got=%d", 15, meta.TOCCount)
+			}
+		})
+	}
+}
+
+func TestTarSourcePrefixedMember(t *testing.T) {
+	t.Parallel()
+
+	dump := readMinDump(t)
+	path := filepath.Join(t.TempDir(), "db.tar")
+	writeTar(t, path, map[string][]byte{"backups/db/toc.dat": dump})
+
+	file, err := extractor.NewFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := extractor.NewTarSource(file, "toc.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	if src.Member() != "backups/db/toc.dat" {
+		t.Errorf("expected=%s, got=%s", "backups/db/toc.dat", src.Member())
+	}
+
+	buf := make([]byte, 5)
+	if _, err := src.ReadAt(buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "PGDMP" {
+		t.Errorf("expected=%s, got=%s", "PGDMP", buf)
+	}
+}
+
+func TestTarSourceMemberNotFound(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "other.tar")
+	writeTar(t, path, map[string][]byte{"notes.txt": []byte("hello")})
+
+	_, err := extractor.OpenSource(context.Background(), path, extractor.SourceOptions{})
+	if !errors.Is(err, extractor.ErrMemberNotFound) {
+		t.Errorf("expected=%v, got=%v", extractor.ErrMemberNotFound, err)
+	}
+}
+
+// memSource is a caller-provided Source, as library users would inject.
+type memSource struct {
+	*bytes.Reader
+}
+
+func (m memSource) Close() error { return nil }
+func (m memSource) Name() string { return "memory" }
+
+func TestScanSourceCustom(t *testing.T) {
+	t.Parallel()
+
+	var src extractor.Source = memSource{bytes.NewReader(readMinDump(t))}
+	if _, ok := src.(io.ReaderAt); !ok {
+		t.Fatal("expected bytes.Reader to provide io.ReaderAt")
+	}
+
+	dump := extractor.ScanSource(src)
+	if dump.Err != nil {
+		t.Fatal(dump.Err)
+	}
+	if dump.Path != "memory" || dump.Database() != "empty_db" {
+		t.Errorf("unexpected dump: %+v", dump)
+	}
+}
@@ -0,0 +1,179 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mble/pgdump-metadata-extractor/archive"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var ErrTableNotFound = errors.New("table data not found")
+
+// ErrSkipRemaining can be returned by a TableFunc to stop Tables early without
+// error, once it has read all it needs.
+var ErrSkipRemaining = errors.New("skip remaining tables")
+
+// tableDataDesc is the TOC entry type holding a table's COPY data.
+const tableDataDesc = "TABLE DATA"
+
+// TableFunc is called with a TABLE DATA entry and its decompressed COPY data.
+type TableFunc func(entry *metadata.TOCEntry, data io.Reader) error
+
+// Tables calls fn with the COPY data of every TABLE DATA entry of src that
+// match accepts, or of every one when match is nil. Custom format archives
+// whose matching entries all have recorded offsets are read at those offsets
+// and otherwise walked block by block, in archive order; dumps keeping their
+// data in separate files are read file by file, in TOC order.
+func Tables(src Source, match func(*metadata.TOCEntry) bool, fn TableFunc) error {
+	err := tables(src, match, fn)
+	if errors.Is(err, ErrSkipRemaining) {
+		return nil
+	}
+
+	return err
+}
+
+// Table calls fn with the COPY data of the table named name, qualified by its
+// schema as in public.orders, stopping as soon as it has been read.
+func Table(src Source, name string, fn TableFunc) error {
+	found := false
+	err := Tables(src, func(entry *metadata.TOCEntry) bool {
+		return entry.QualifiedName() == name
+	}, func(entry *metadata.TOCEntry, data io.Reader) error {
+		found = true
+		if err := fn(entry, data); err != nil {
+			return err
+		}
+		return ErrSkipRemaining
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, name)
+	}
+
+	return nil
+}
+
+func tables(src Source, match func(*metadata.TOCEntry) bool, fn TableFunc) error {
+	selected := func(entry *metadata.TOCEntry) bool {
+		return entry != nil && entry.Desc == tableDataDesc && (match == nil || match(entry))
+	}
+
+	if _, ok := src.(*TarSource); ok {
+		d, err := OpenDir(src)
+		if err != nil {
+			return err
+		}
+		return dirTables(d, selected, fn)
+	}
+
+	a, err := OpenArchive(src)
+	if errors.Is(err, ErrNotRandomAccess) {
+		r, readerErr := archive.NewReader(src)
+		if readerErr != nil {
+			return readerErr
+		}
+		return walkTables(r, selected, fn)
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.Metadata.Format != "CUSTOM" {
+		d, dirErr := OpenDir(src)
+		if dirErr != nil {
+			return dirErr
+		}
+		return dirTables(d, selected, fn)
+	}
+
+	for i := range a.Entries {
+		if selected(&a.Entries[i]) && a.Entries[i].DataState != metadata.OffsetPosSet {
+			r, err := a.Walk()
+			if err != nil {
+				return err
+			}
+			return walkTables(r, selected, fn)
+		}
+	}
+
+	return archiveTables(a, selected, fn)
+}
+
+func walkTables(r *archive.Reader, selected func(*metadata.TOCEntry) bool, fn TableFunc) error {
+	for {
+		block, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		entry := r.Entry(block.DumpID)
+		if block.Type != archive.BlockData || !selected(entry) {
+			continue
+		}
+
+		data, err := block.Data()
+		if err != nil {
+			return err
+		}
+		if err := callTable(fn, entry, data); err != nil {
+			return err
+		}
+	}
+}
+
+func archiveTables(a *archive.Archive, selected func(*metadata.TOCEntry) bool, fn TableFunc) error {
+	for i := range a.Entries {
+		entry := &a.Entries[i]
+		if !selected(entry) {
+			continue
+		}
+
+		data, err := a.Data(entry.DumpID)
+		if err != nil {
+			return err
+		}
+		if err := callTable(fn, entry, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dirTables(d *archive.Dir, selected func(*metadata.TOCEntry) bool, fn TableFunc) error {
+	for i := range d.Entries {
+		entry := &d.Entries[i]
+		if !selected(entry) || entry.FileName == "" {
+			continue
+		}
+
+		data, err := d.Data(entry.DumpID)
+		if err != nil {
+			return err
+		}
+		if err := callTable(fn, entry, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// callTable passes data to fn, closing it afterwards.
+func callTable(fn TableFunc, entry *metadata.TOCEntry, data io.ReadCloser) error {
+	err := fn(entry, data)
+	_ = data.Close()
+	if err != nil && !errors.Is(err, ErrSkipRemaining) {
+		return fmt.Errorf("err reading data of %s: %w", entry.QualifiedName(), err)
+	}
+
+	return err
+}
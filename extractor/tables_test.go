@@ -0,0 +1,174 @@
+package extractor_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+func tablesArchive(piped bool) *dumptest.Archive {
+	return &dumptest.Archive{
+		Piped: piped,
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop"},
+			{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", Data: []byte("1\n2\n")},
+			{Tag: "users", Desc: "TABLE DATA", Namespace: "auth", Owner: "shop", Data: []byte("alice\n")},
+		},
+	}
+}
+
+// streamSource hides the io.ReaderAt of its reader, as stdin would.
+type streamSource struct {
+	io.Reader
+}
+
+func (s streamSource) Close() error { return nil }
+func (s streamSource) Name() string { return "stream" }
+func (s streamSource) Size() int64  { return -1 }
+
+func TestTables(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	withOffsets := filepath.Join(dir, "offsets.dump")
+	if err := os.WriteFile(withOffsets, tablesArchive(false).Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	piped := filepath.Join(dir, "piped.dump")
+	if err := os.WriteFile(piped, tablesArchive(true).Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	dumpDir := filepath.Join(dir, "db.dir")
+	if err := os.Mkdir(dumpDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	dirArchive := &dumptest.Archive{Format: 5} // DIRECTORY
+	for i, entry := range tablesArchive(false).Entries {
+		if entry.Data != nil {
+			entry.FileName = strconv.Itoa(i+1) + ".dat"
+			if err := os.WriteFile(filepath.Join(dumpDir, entry.FileName), entry.Data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			entry.Data, entry.Section = nil, dumptest.SectionData
+		}
+		dirArchive.Entries = append(dirArchive.Entries, entry)
+	}
+	if err := os.WriteFile(filepath.Join(dumpDir, "toc.dat"), dirArchive.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	members := map[string][]byte{}
+	entries, err := os.ReadDir(dumpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dumpDir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		members["backups/db.dir/"+entry.Name()] = data
+	}
+	tarPath := filepath.Join(dir, "db.dir.tar")
+	writeTar(t, tarPath, members)
+	gzipFile(t, tarPath)
+
+	want := map[string]string{"public.orders": "1\n2\n", "auth.users": "alice\n"}
+
+	testCases := []struct {
+		desc string
+		src  func(t *testing.T) extractor.Source
+	}{
+		{desc: "offsets", src: func(t *testing.T) extractor.Source { return mustOpen(t, withOffsets) }},
+		{desc: "piped", src: func(t *testing.T) extractor.Source { return mustOpen(t, piped) }},
+		{desc: "stream", src: func(t *testing.T) extractor.Source { return streamSource{mustOpen(t, piped)} }},
+		{desc: "directory", src: func(t *testing.T) extractor.Source { return mustOpen(t, dumpDir) }},
+		{desc: "tar", src: func(t *testing.T) extractor.Source { return mustOpen(t, tarPath) }},
+		{desc: "tar.gz", src: func(t *testing.T) extractor.Source { return mustOpen(t, tarPath+".gz") }},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got := make(map[string]string)
+			err := extractor.Tables(tC.src(t), nil, func(entry *metadata.TOCEntry, data io.Reader) error {
+				b, err := io.ReadAll(data)
+				got[entry.QualifiedName()] = string(b)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("expected=%v, got=%v", want, got)
+			}
+		})
+	}
+}
+
+func TestTablesMatchAndSkip(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "piped.dump")
+	if err := os.WriteFile(path, tablesArchive(true).Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	err := extractor.Tables(mustOpen(t, path), func(entry *metadata.TOCEntry) bool {
+		return entry.Namespace == "public"
+	}, func(entry *metadata.TOCEntry, _ io.Reader) error {
+		seen = append(seen, entry.QualifiedName())
+		return extractor.ErrSkipRemaining
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seen, []string{"public.orders"}) {
+		t.Errorf("expected=%v, got=%v", []string{"public.orders"}, seen)
+	}
+
+	errStop := errors.New("stop")
+	err = extractor.Tables(mustOpen(t, path), nil, func(*metadata.TOCEntry, io.Reader) error {
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("expected=%v, got=%v", errStop, err)
+	}
+}
+
+func TestTable(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "piped.dump")
+	if err := os.WriteFile(path, tablesArchive(true).Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []byte
+	err := extractor.Table(mustOpen(t, path), "auth.users", func(_ *metadata.TOCEntry, data io.Reader) error {
+		var err error
+		got, err = io.ReadAll(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "alice\n" {
+		t.Errorf("expected=%q, got=%q", "alice\n", got)
+	}
+
+	err = extractor.Table(mustOpen(t, path), "users", func(*metadata.TOCEntry, io.Reader) error { return nil })
+	if !errors.Is(err, extractor.ErrTableNotFound) {
+		t.Errorf("expected=%v, got=%v", extractor.ErrTableNotFound, err)
+	}
+}
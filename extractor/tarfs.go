@@ -0,0 +1,150 @@
+package extractor
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// FS maps the members of the tar stream that sit alongside the member being
+// read, such as the data files of a directory format dump packed with its
+// toc.dat, to a read-only filesystem rooted at the member's directory. Any
+// leading path in the tar is stripped. A tar.gz read from a file is
+// decompressed to a temporary file, removed on Close, to be mapped; one read
+// from a stream such as stdin can't be mapped, as its earlier members are
+// gone.
+func (t *TarSource) FS() (fs.FS, error) {
+	at, size, err := t.members()
+	if err != nil {
+		return nil, err
+	}
+
+	section := io.NewSectionReader(at, 0, size)
+	tr := tar.NewReader(section)
+	dir := path.Dir(path.Clean(t.member))
+	fsys := tarFS{}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("err reading tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if dir != "." {
+			if !strings.HasPrefix(name, dir+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(name, dir+"/")
+		}
+
+		// Seeking to the current position reports where the member's data
+		// starts without reading it.
+		start, err := section.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("err reading tar: %w", err)
+		}
+		fsys[name] = tarMember{hdr: hdr, r: io.NewSectionReader(at, start, hdr.Size)}
+	}
+}
+
+// members returns the whole tar for random access, spooling the
+// decompressed contents of a tar.gz when its file can be read again from
+// the start.
+func (t *TarSource) members() (io.ReaderAt, int64, error) {
+	if at, err := RandomAccess(t.base); err == nil {
+		return at, t.base.Size(), nil
+	}
+	if t.spool != nil {
+		info, err := t.spool.Stat()
+		if err != nil {
+			return nil, 0, fmt.Errorf("err stating spool file: %w", err)
+		}
+		return t.spool, info.Size(), nil
+	}
+
+	gz := gzipSourceOf(t.base)
+	if gz == nil {
+		return nil, 0, fmt.Errorf("%w: %s", ErrNotRandomAccess, t.Name())
+	}
+	compressed, err := RandomAccess(gz.base)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: data members of a gzipped tar can't be read from a stream", err)
+	}
+
+	zr, err := gzip.NewReader(io.NewSectionReader(compressed, 0, gz.base.Size()))
+	if err != nil {
+		return nil, 0, fmt.Errorf("err decompressing %s: %w", t.Name(), err)
+	}
+
+	spool, err := os.CreateTemp("", "pgdump-tar-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("err creating spool file: %w", err)
+	}
+	size, err := io.Copy(spool, zr)
+	if err != nil {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+		return nil, 0, fmt.Errorf("err decompressing %s: %w", t.Name(), err)
+	}
+	t.spool = spool
+
+	return spool, size, nil
+}
+
+// gzipSourceOf returns the GzipSource src reads from, if any.
+func gzipSourceOf(src Source) *GzipSource {
+	for {
+		switch s := src.(type) {
+		case *GzipSource:
+			return s
+		case *peekSource:
+			src = s.Source
+		default:
+			return nil
+		}
+	}
+}
+
+// tarMember is a regular file in a tar and where its data lies.
+type tarMember struct {
+	hdr *tar.Header
+	r   *io.SectionReader
+}
+
+// tarFS is a flat filesystem of tar members, keyed by their path.
+type tarFS map[string]tarMember
+
+func (t tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	member, ok := t[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return &tarFile{SectionReader: io.NewSectionReader(member.r, 0, member.r.Size()), hdr: member.hdr}, nil
+}
+
+// tarFile is an open tar member.
+type tarFile struct {
+	*io.SectionReader
+	hdr *tar.Header
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.hdr.FileInfo(), nil }
+func (f *tarFile) Close() error               { return nil }
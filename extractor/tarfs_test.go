@@ -0,0 +1,186 @@
+package extractor_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+)
+
+func dirDump() []byte {
+	return (&dumptest.Archive{
+		Database: "shop",
+		Format:   5, // DIRECTORY
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop"},
+			{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", FileName: "2.dat", Section: dumptest.SectionData},
+		},
+	}).Bytes()
+}
+
+func gzipFile(t *testing.T, path string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path+".gz", buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenSourcePackedDir(t *testing.T) {
+	t.Parallel()
+
+	toc := dirDump()
+	dir := t.TempDir()
+
+	unpacked := filepath.Join(dir, "db.dir")
+	if err := os.Mkdir(unpacked, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(unpacked, "toc.dat"), toc, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tarPath := filepath.Join(dir, "db.dir.tar")
+	writeTar(t, tarPath, map[string][]byte{"backups/db.dir/toc.dat": toc})
+	gzipFile(t, tarPath)
+
+	want, err := extractor.Extract(mustOpen(t, unpacked))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{tarPath, tarPath + ".gz"} {
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			t.Parallel()
+
+			got, err := extractor.Extract(mustOpen(t, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Format != "DIRECTORY" || *got.DatabaseName != *want.DatabaseName || got.TOCCount != want.TOCCount {
+				t.Errorf("expected=%+v, got=%+v", want, got)
+			}
+		})
+	}
+}
+
+func TestOpenDirTar(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "db.dir.tar")
+	writeTar(t, tarPath, map[string][]byte{
+		"backups/db.dir/2.dat":   []byte("1\n2\n"),
+		"backups/db.dir/toc.dat": dirDump(),
+		"backups/other/2.dat":    []byte("other\n"),
+	})
+	gzipFile(t, tarPath)
+
+	d, err := extractor.OpenDir(mustOpen(t, tarPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := d.Data(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+
+	got, err := io.ReadAll(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "1\n2\n" {
+		t.Errorf("expected=%q, got=%q", "1\n2\n", got)
+	}
+
+	packed, err := os.ReadFile(tarPath + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := extractor.NewGzipSource(streamSource{bytes.NewReader(packed)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := extractor.NewTarSource(gz, "toc.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Members before toc.dat are gone once a stream has been read past them.
+	if _, err := extractor.OpenDir(stream); !errors.Is(err, extractor.ErrNotRandomAccess) {
+		t.Errorf("expected=%v, got=%v", extractor.ErrNotRandomAccess, err)
+	}
+}
+
+func mustOpen(t *testing.T, name string) extractor.Source {
+	t.Helper()
+
+	src, err := extractor.OpenSource(context.Background(), name, extractor.SourceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = src.Close() })
+
+	return src
+}
+
+func TestOpenDirFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	archived := (&dumptest.Archive{
+		Database: "legacy",
+		VMin:     9,
+		Format:   2, // FILE
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", FileName: "1.dat"},
+		},
+	}).Bytes()
+	if err := os.WriteFile(filepath.Join(dir, "legacy.dump"), archived, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "1.dat"), []byte("1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := extractor.OpenDir(mustOpen(t, filepath.Join(dir, "legacy.dump")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := d.Data(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+
+	got, err := io.ReadAll(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "1\n" {
+		t.Errorf("expected=%q, got=%q", "1\n", got)
+	}
+}
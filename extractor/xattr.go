@@ -0,0 +1,198 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var ErrXattrUnsupported = errors.New("extended attributes not supported on this platform")
+var ErrXattrMissing = errors.New("extended attributes not present")
+var ErrXattrStale = errors.New("extended attributes do not match file")
+
+// xattrPrefix namespaces every attribute written by the extractor.
+const xattrPrefix = "user.pgdump."
+
+const (
+	xattrDatabase        = xattrPrefix + "database"
+	xattrCreated         = xattrPrefix + "created"
+	xattrTimeIsDST       = xattrPrefix + "time_is_dst"
+	xattrPGDumpVersion   = xattrPrefix + "pgdump_version"
+	xattrRemoteVersion   = xattrPrefix + "remote_version"
+	xattrArchiveVersion  = xattrPrefix + "archive_version"
+	xattrIntSize         = xattrPrefix + "int_size"
+	xattrOffSize         = xattrPrefix + "off_size"
+	xattrCompression     = xattrPrefix + "compression"
+	xattrCompressionSpec = xattrPrefix + "compression_spec"
+	xattrTOCCount        = xattrPrefix + "toc_count"
+	xattrFormat          = xattrPrefix + "format"
+	xattrNulls           = xattrPrefix + "nulls"
+	xattrSize            = xattrPrefix + "size"
+	xattrMtime           = xattrPrefix + "mtime"
+)
+
+// xattrNames lists every attribute that must be present for a cache hit, so
+// attributes written by earlier versions, which cached fewer fields, fall
+// back to parsing the dump.
+var xattrNames = [...]string{
+	xattrDatabase,
+	xattrCreated,
+	xattrTimeIsDST,
+	xattrPGDumpVersion,
+	xattrRemoteVersion,
+	xattrArchiveVersion,
+	xattrIntSize,
+	xattrOffSize,
+	xattrCompression,
+	xattrCompressionSpec,
+	xattrTOCCount,
+	xattrFormat,
+	xattrNulls,
+	xattrSize,
+	xattrMtime,
+}
+
+// xattrCreatedLayout formats the creation time fields as recorded, with a
+// one-based month, so that they read back unchanged whatever the time zone.
+const xattrCreatedLayout = "%04d-%02d-%02d %02d:%02d:%02d"
+
+// StoreXattrs writes the header fields of m into user.pgdump.* extended
+// attributes on the file at path, alongside its current size and mtime.
+// String fields that are NULL in the header are listed in
+// user.pgdump.nulls, so they aren't confused with empty strings.
+func StoreXattrs(path string, m *metadata.Metadata) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("err stating file: %w", err)
+	}
+
+	attrs := map[string]string{
+		xattrCreated:        fmt.Sprintf(xattrCreatedLayout, m.TimeYear, m.TimeMonth+1, m.TimeDay, m.TimeHour, m.TimeMin, m.TimeSec),
+		xattrTimeIsDST:      strconv.Itoa(m.TimeIsDST),
+		xattrArchiveVersion: fmt.Sprintf("%d.%d.%d", m.VMain, m.VMin, m.VRev),
+		xattrIntSize:        strconv.Itoa(int(m.IntSize)),
+		xattrOffSize:        strconv.Itoa(int(m.OffSize)),
+		xattrCompression:    strconv.Itoa(m.Compression),
+		xattrTOCCount:       strconv.Itoa(m.TOCCount),
+		xattrFormat:         m.Format,
+		xattrSize:           strconv.FormatInt(info.Size(), 10),
+		xattrMtime:          strconv.FormatInt(info.ModTime().UnixNano(), 10),
+	}
+
+	var nulls []string
+	for name, val := range map[string]*string{
+		xattrDatabase:        m.DatabaseName,
+		xattrPGDumpVersion:   m.PGDumpVersion,
+		xattrRemoteVersion:   m.RemoteVersion,
+		xattrCompressionSpec: m.CompressionSpec,
+	} {
+		if val == nil {
+			nulls = append(nulls, strings.TrimPrefix(name, xattrPrefix))
+			continue
+		}
+		attrs[name] = *val
+	}
+	sort.Strings(nulls)
+	attrs[xattrNulls] = strings.Join(nulls, ",")
+
+	for _, name := range xattrNames {
+		if err := setxattr(path, name, attrs[name]); err != nil {
+			return fmt.Errorf("err setting xattr %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// LoadXattrs reconstructs metadata from the user.pgdump.* extended attributes
+// on the file at path without parsing it. ErrXattrStale is returned when the
+// recorded size or mtime no longer match the file, and ErrXattrMissing when
+// any field isn't cached.
+func LoadXattrs(path string) (metadata.Metadata, error) {
+	m := metadata.Metadata{}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return m, fmt.Errorf("err stating file: %w", err)
+	}
+
+	attrs := make(map[string]string, len(xattrNames))
+	for _, name := range xattrNames {
+		val, err := getxattr(path, name)
+		if err != nil {
+			return m, err
+		}
+		attrs[name] = val
+	}
+
+	if attrs[xattrSize] != strconv.FormatInt(info.Size(), 10) ||
+		attrs[xattrMtime] != strconv.FormatInt(info.ModTime().UnixNano(), 10) {
+		return m, ErrXattrStale
+	}
+
+	if _, err := fmt.Sscanf(attrs[xattrCreated], xattrCreatedLayout, &m.TimeYear, &m.TimeMonth, &m.TimeDay, &m.TimeHour, &m.TimeMin, &m.TimeSec); err != nil {
+		return m, fmt.Errorf("err parsing %s: %w", xattrCreated, err)
+	}
+	m.TimeMonth--
+
+	if _, err := fmt.Sscanf(attrs[xattrArchiveVersion], "%d.%d.%d", &m.VMain, &m.VMin, &m.VRev); err != nil {
+		return m, fmt.Errorf("err parsing %s: %w", xattrArchiveVersion, err)
+	}
+
+	ints := map[string]*int{
+		xattrTimeIsDST:   &m.TimeIsDST,
+		xattrCompression: &m.Compression,
+		xattrTOCCount:    &m.TOCCount,
+	}
+	for name, dst := range ints {
+		if *dst, err = strconv.Atoi(attrs[name]); err != nil {
+			return m, fmt.Errorf("err parsing %s: %w", name, err)
+		}
+	}
+
+	sizes := map[string]*uint8{
+		xattrIntSize: &m.IntSize,
+		xattrOffSize: &m.OffSize,
+	}
+	for name, dst := range sizes {
+		size, err := strconv.ParseUint(attrs[name], 10, 8)
+		if err != nil {
+			return m, fmt.Errorf("err parsing %s: %w", name, err)
+		}
+		*dst = uint8(size)
+	}
+
+	nulls := strings.Split(attrs[xattrNulls], ",")
+	strs := map[string]**string{
+		xattrDatabase:        &m.DatabaseName,
+		xattrPGDumpVersion:   &m.PGDumpVersion,
+		xattrRemoteVersion:   &m.RemoteVersion,
+		xattrCompressionSpec: &m.CompressionSpec,
+	}
+	for name, dst := range strs {
+		if slices.Contains(nulls, strings.TrimPrefix(name, xattrPrefix)) {
+			continue
+		}
+		val := attrs[name]
+		*dst = &val
+	}
+
+	m.Magic = "PGDMP"
+	m.Format = attrs[xattrFormat]
+
+	return m, nil
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
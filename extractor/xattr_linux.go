@@ -0,0 +1,38 @@
+//go:build linux
+
+package extractor
+
+import (
+	"errors"
+	"syscall"
+)
+
+func setxattr(path, name, value string) error {
+	return syscall.Setxattr(path, name, []byte(value), 0)
+}
+
+func getxattr(path, name string) (string, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil {
+		return "", mapXattrErr(err)
+	}
+
+	buf := make([]byte, size)
+	n, err := syscall.Getxattr(path, name, buf)
+	if err != nil {
+		return "", mapXattrErr(err)
+	}
+
+	return string(buf[:n]), nil
+}
+
+func mapXattrErr(err error) error {
+	switch {
+	case errors.Is(err, syscall.ENODATA):
+		return ErrXattrMissing
+	case errors.Is(err, syscall.ENOTSUP):
+		return ErrXattrUnsupported
+	}
+
+	return err
+}
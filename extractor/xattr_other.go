@@ -0,0 +1,11 @@
+//go:build !linux
+
+package extractor
+
+func setxattr(_, _, _ string) error {
+	return ErrXattrUnsupported
+}
+
+func getxattr(_, _ string) (string, error) {
+	return "", ErrXattrUnsupported
+}
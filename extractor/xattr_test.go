@@ -0,0 +1,143 @@
+package extractor_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+func copyMinDump(t *testing.T) string {
+	t.Helper()
+
+	data, err := os.ReadFile("../testdata/min.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "min.dump")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func storeOrSkip(t *testing.T, path string, m *metadata.Metadata) {
+	t.Helper()
+
+	err := extractor.StoreXattrs(path, m)
+	if errors.Is(err, extractor.ErrXattrUnsupported) {
+		t.Skip("xattrs not supported here")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestXattrsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path := copyMinDump(t)
+	fd, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+
+	meta, err := extractor.Extract(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storeOrSkip(t, path, &meta)
+
+	cached, err := extractor.LoadXattrs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := meta.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cached.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("expected=%s, got=%s", want, got)
+	}
+}
+
+func TestXattrsNulls(t *testing.T) {
+	t.Parallel()
+
+	empty, spec := "", "zstd:level=3"
+	testCases := []struct {
+		desc string
+		meta metadata.Metadata
+	}{
+		{
+			desc: "old archive",
+			meta: metadata.Metadata{Magic: "PGDMP", Format: "FILE", VMain: 1, VMin: 3, IntSize: 4, OffSize: 4, Compression: 1, TOCCount: 2},
+		},
+		{
+			desc: "empty strings",
+			meta: metadata.Metadata{Magic: "PGDMP", Format: "CUSTOM", VMain: 1, VMin: 15, IntSize: 4, OffSize: 8, DatabaseName: &empty, PGDumpVersion: &empty, RemoteVersion: &empty, CompressionSpec: &spec, TimeIsDST: 1},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			path := copyMinDump(t)
+			storeOrSkip(t, path, &tC.meta)
+
+			cached, err := extractor.LoadXattrs(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tC.meta, cached) {
+				t.Errorf("expected=%+v, got=%+v", tC.meta, cached)
+			}
+		})
+	}
+}
+
+func TestXattrsStale(t *testing.T) {
+	t.Parallel()
+
+	path := copyMinDump(t)
+	meta := metadata.Metadata{Format: "CUSTOM", TimeYear: 2021}
+	storeOrSkip(t, path, &meta)
+
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := extractor.LoadXattrs(path)
+	if !errors.Is(err, extractor.ErrXattrStale) {
+		t.Errorf("expected=%v, got=%v", extractor.ErrXattrStale, err)
+	}
+}
+
+func TestXattrsMissing(t *testing.T) {
+	t.Parallel()
+
+	path := copyMinDump(t)
+
+	_, err := extractor.LoadXattrs(path)
+	if errors.Is(err, extractor.ErrXattrUnsupported) {
+		t.Skip("xattrs not supported here")
+	}
+	if !errors.Is(err, extractor.ErrXattrMissing) {
+		t.Errorf("expected=%v, got=%v", extractor.ErrXattrMissing, err)
+	}
+}
@@ -0,0 +1,416 @@
+// Package dumptest builds synthetic pg_dump archives for tests.
+package dumptest
+
+import (
+	"bytes"
+	"compress/zlib"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// IntSize is the int size used for every archive built by this package.
+const IntSize = 4
+
+// OffSize is the offset size used for every archive built by this package.
+const OffSize = 8
+
+const defaultChunkSize = 4096
+
+// Block types of custom format data blocks.
+const (
+	blkData  = 1
+	blkBlobs = 3
+)
+
+// Offset flags of custom format TOC entries.
+const (
+	offsetPosNotSet = 1
+	offsetPosSet    = 2
+	offsetNoData    = 3
+)
+
+// Sections of TOC entries.
+const (
+	SectionPreData  = 2
+	SectionData     = 3
+	SectionPostData = 4
+)
+
+// Entry describes a TOC entry to build.
+type Entry struct {
+	Tag       string
+	Desc      string
+	Namespace string
+	Owner     string
+	Defn      string
+	CopyStmt  string
+	// FileName is written for formats that keep data outside the archive.
+	FileName string
+	Deps     []int
+	// Data is the COPY data of the entry; nil for entries without data.
+	Data []byte
+	// Blobs are written as a blob block, for a BLOBS entry, instead of Data.
+	Blobs []Blob
+	// DumpID defaults to the entry's position, counting from 1.
+	DumpID int
+	// Section defaults to SectionData for entries with data and
+	// SectionPreData otherwise.
+	Section int
+}
+
+// Blob is a large object of a BLOBS entry.
+type Blob struct {
+	OID  int
+	Data []byte
+}
+
+// hasData reports whether the entry has a data block.
+func (e *Entry) hasData() bool {
+	return e.Data != nil || e.Blobs != nil
+}
+
+// Archive describes a custom-format archive to build.
+type Archive struct {
+	Created       time.Time
+	Database      string
+	RemoteVersion string
+	PGDumpVersion string
+	Entries       []Entry
+	TOCCount      int
+	Compression   int
+	// Algorithm names the compression algorithm recorded by 1.15+ archives,
+	// overriding Compression. Only gzip data can be built, so data of other
+	// algorithms is written as is.
+	Algorithm string
+	// ChunkSize is the maximum size of each data chunk.
+	ChunkSize int
+	// VMin is the minor archive version, defaulting to 13. Fields introduced
+	// by later versions are left out.
+	VMin uint8
+	// Format is the format index, defaulting to CUSTOM.
+	Format uint8
+	// Piped leaves data offsets unset, as pg_dump does when writing to a pipe.
+	Piped bool
+}
+
+func (a *Archive) vmin() uint8 {
+	if a.VMin == 0 {
+		return 13
+	}
+
+	return a.VMin
+}
+
+// algorithms are the compression algorithms of 1.16+ archives, by index.
+var algorithms = []string{"none", "gzip", "lz4", "zstd"}
+
+func (a *Archive) algorithm() string {
+	switch {
+	case a.Algorithm != "":
+		return a.Algorithm
+	case a.Compression != 0:
+		return "gzip"
+	}
+
+	return "none"
+}
+
+func (a *Archive) format() uint8 {
+	if a.Format == 0 {
+		return 1
+	}
+
+	return a.Format
+}
+
+// Bytes encodes the archive, its TOC and its data blocks.
+func (a *Archive) Bytes() []byte {
+	blocks := a.DataBlocks()
+
+	// The TOC has the same length whatever the offsets, so encode it once to
+	// learn where the data starts and again with the real offsets.
+	head := a.header(nil)
+	offsets := make([]int64, len(a.Entries))
+	pos := int64(len(head))
+	for i := range a.Entries {
+		if blocks[i] != nil {
+			offsets[i] = pos
+			pos += int64(len(blocks[i]))
+		}
+	}
+
+	out := a.header(offsets)
+	for _, block := range blocks {
+		out = append(out, block...)
+	}
+
+	return out
+}
+
+// DataBlocks returns the encoded data block of each entry, or nil for
+// entries without data.
+func (a *Archive) DataBlocks() [][]byte {
+	blocks := make([][]byte, len(a.Entries))
+	for i := range a.Entries {
+		switch e := &a.Entries[i]; {
+		case e.Blobs != nil:
+			blocks[i] = a.blobBlock(a.dumpID(i), e.Blobs)
+		case e.Data != nil:
+			blocks[i] = a.dataBlock(a.dumpID(i), e.Data)
+		}
+	}
+
+	return blocks
+}
+
+func (a *Archive) dumpID(i int) int {
+	if a.Entries[i].DumpID != 0 {
+		return a.Entries[i].DumpID
+	}
+
+	return i + 1
+}
+
+func (a *Archive) header(offsets []int64) []byte {
+	var buf bytes.Buffer
+
+	created := a.Created
+	if created.IsZero() {
+		created = time.Date(2021, time.June, 3, 18, 53, 33, 0, time.Local)
+	}
+
+	buf.WriteString("PGDMP")
+	buf.WriteByte(1)        // vmain
+	buf.WriteByte(a.vmin()) // vmin
+	buf.WriteByte(0)        // vrev
+	buf.WriteByte(IntSize)  // int size
+	if a.vmin() >= 7 {
+		buf.WriteByte(OffSize) // off size
+	}
+	buf.WriteByte(a.format()) // format
+	switch {
+	case a.vmin() >= 16:
+		buf.WriteByte(byte(slices.Index(algorithms, a.algorithm())))
+	case a.vmin() == 15:
+		buf.Write(String(a.algorithm()))
+	case a.vmin() >= 4:
+		buf.Write(Int(int64(a.Compression)))
+	default:
+		buf.WriteByte(byte(a.Compression))
+	}
+	if a.vmin() >= 4 {
+		buf.Write(Int(int64(created.Second())))
+		buf.Write(Int(int64(created.Minute())))
+		buf.Write(Int(int64(created.Hour())))
+		buf.Write(Int(int64(created.Day())))
+		buf.Write(Int(int64(created.Month()) - 1))
+		buf.Write(Int(int64(created.Year() - 1900)))
+		buf.Write(Int(0))
+		buf.Write(String(a.Database))
+	}
+	if a.vmin() >= 10 {
+		buf.Write(String(a.RemoteVersion))
+		buf.Write(String(a.PGDumpVersion))
+	}
+
+	count := a.TOCCount
+	if count == 0 {
+		count = len(a.Entries)
+	}
+	buf.Write(Int(int64(count)))
+
+	for i := range a.Entries {
+		var offset int64
+		if offsets != nil {
+			offset = offsets[i]
+		}
+		buf.Write(a.tocEntry(i, offset))
+	}
+
+	return buf.Bytes()
+}
+
+func (a *Archive) tocEntry(i int, offset int64) []byte {
+	var buf bytes.Buffer
+	e := &a.Entries[i]
+
+	section := e.Section
+	if section == 0 {
+		section = SectionPreData
+		if e.hasData() {
+			section = SectionData
+		}
+	}
+
+	hadDumper := int64(0)
+	if e.hasData() {
+		hadDumper = 1
+	}
+
+	buf.Write(Int(int64(a.dumpID(i))))
+	buf.Write(Int(hadDumper))
+	if a.vmin() >= 8 {
+		buf.Write(String("1259")) // tableoid
+	}
+	buf.Write(String("16384"))
+	buf.Write(String(e.Tag))
+	buf.Write(String(e.Desc))
+	if a.vmin() >= 11 {
+		buf.Write(Int(int64(section)))
+	}
+	buf.Write(String(e.Defn))
+	buf.Write(String(""))
+	if a.vmin() >= 3 {
+		buf.Write(String(e.CopyStmt))
+	}
+	if a.vmin() >= 6 {
+		buf.Write(String(e.Namespace))
+	}
+	if a.vmin() >= 10 {
+		buf.Write(String("")) // tablespace
+	}
+	if a.vmin() >= 14 {
+		buf.Write(String("heap"))
+	}
+	if a.vmin() >= 16 {
+		buf.Write(Int('r'))
+	}
+	buf.Write(String(e.Owner))
+	if a.vmin() >= 9 {
+		buf.Write(String("false"))
+	}
+	if a.vmin() >= 5 {
+		for _, dep := range e.Deps {
+			buf.Write(String(strconv.Itoa(dep)))
+		}
+		buf.Write(Int(-1)) // end of deps
+	}
+
+	switch a.format() {
+	case 1: // CUSTOM
+		switch {
+		case a.vmin() < 7:
+			// Before offset flags, the position is a plain int, negative
+			// when unset and 0 without data, followed by an unused size.
+			pos := int64(0)
+			if e.hasData() {
+				pos = offset
+				if a.Piped {
+					pos = -1
+				}
+			}
+			buf.Write(Int(pos))
+			buf.Write(Int(0))
+		case !e.hasData():
+			buf.Write(Offset(offsetNoData, 0))
+		case a.Piped:
+			buf.Write(Offset(offsetPosNotSet, 0))
+		default:
+			buf.Write(Offset(offsetPosSet, offset))
+		}
+	default:
+		buf.Write(String(e.FileName))
+	}
+
+	return buf.Bytes()
+}
+
+// blobBlock encodes blobs as a custom format blob block for dumpID.
+func (a *Archive) blobBlock(dumpID int, blobs []Blob) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(blkBlobs)
+	buf.Write(Int(int64(dumpID)))
+	for _, blob := range blobs {
+		buf.Write(Int(int64(blob.OID)))
+		for _, chunk := range a.Chunks(blob.Data) {
+			buf.Write(Int(int64(len(chunk))))
+			buf.Write(chunk)
+		}
+		buf.Write(Int(0))
+	}
+	buf.Write(Int(0))
+
+	return buf.Bytes()
+}
+
+// dataBlock encodes data as a custom format data block for dumpID.
+func (a *Archive) dataBlock(dumpID int, data []byte) []byte {
+	var buf bytes.Buffer
+
+	buf.WriteByte(blkData)
+	buf.Write(Int(int64(dumpID)))
+	for _, chunk := range a.Chunks(data) {
+		buf.Write(Int(int64(len(chunk))))
+		buf.Write(chunk)
+	}
+	buf.Write(Int(0))
+
+	return buf.Bytes()
+}
+
+// Chunks compresses data if the archive is compressed and splits it into
+// chunks.
+func (a *Archive) Chunks(data []byte) [][]byte {
+	if a.algorithm() == "gzip" {
+		data = Compress(data)
+	}
+
+	size := a.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := min(size, len(data))
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+
+	return chunks
+}
+
+// Compress returns data as a zlib stream.
+func Compress(data []byte) []byte {
+	var buf bytes.Buffer
+
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+
+	return buf.Bytes()
+}
+
+// Int encodes val as a pg_dump sign-and-magnitude integer.
+func Int(val int64) []byte {
+	out := make([]byte, 1+IntSize)
+	if val < 0 {
+		out[0] = 1
+		val = -val
+	}
+	for i := 0; i < IntSize; i++ {
+		out[1+i] = byte(val & 0xff)
+		val >>= 8
+	}
+
+	return out
+}
+
+// String encodes val as a length-prefixed pg_dump string.
+func String(val string) []byte {
+	return append(Int(int64(len(val))), val...)
+}
+
+// Offset encodes a custom format data offset with its flag.
+func Offset(flag byte, val int64) []byte {
+	out := make([]byte, 1+OffSize)
+	out[0] = flag
+	for i := 0; i < OffSize; i++ {
+		out[1+i] = byte(val & 0xff)
+		val >>= 8
+	}
+
+	return out
+}
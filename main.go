@@ -1,40 +1,85 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 
+	"github.com/mble/pgdump-metadata-extractor/archive"
 	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+	"github.com/mble/pgdump-metadata-extractor/s3"
 )
 
 func run(cfg extractor.Cfg) error {
+	name := cfg.FileName
+	if cfg.Stdin {
+		name = "-"
+	}
+
+	if s3.IsPrefixURL(name) {
+		return runS3Prefix(&cfg)
+	}
+
+	fd, err := extractor.OpenSource(context.Background(), name, cfg.SourceOptions())
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	if cfg.Lenient {
+		partial, partialErr := archive.ReadPartial(fd)
+		if partialErr != nil {
+			return partialErr
+		}
+
+		return printJSON(partial)
+	}
+
+	if cfg.Xattrs {
+		if cached, cacheErr := extractor.LoadXattrs(cfg.FileName); cacheErr == nil {
+			out, jsonErr := cached.ToJSON()
+			if jsonErr != nil {
+				return jsonErr
+			}
+
+			fmt.Printf("%s\n", out)
+
+			return nil
+		}
+	}
+
 	var (
-		fd  *os.File
-		err error
+		data metadata.Metadata
+		json []byte
 	)
 
-	switch {
-	case cfg.Stdin:
-		fd = os.Stdin
-	case cfg.FileName != "":
-		fd, err = os.Open(cfg.FileName)
-		if err != nil {
-			err = fmt.Errorf("err opening file: %w", err)
+	if cfg.Hash {
+		hashed, hashErr := extractor.ExtractHashed(fd)
+		if hashErr != nil {
+			return hashErr
+		}
+
+		data = hashed.Metadata
+		if json, err = hashed.ToJSON(); err != nil {
+			return err
+		}
+	} else {
+		if data, err = extractor.Extract(fd); err != nil {
 			return err
 		}
-	}
 
-	defer func() {
-		if fd != nil && fd != os.Stdin {
-			_ = fd.Close()
+		if json, err = data.ToJSON(); err != nil {
+			return err
 		}
-	}()
+	}
 
-	json, err := extractor.Run(fd)
-	if err != nil {
-		return err
+	if cfg.WriteXattrs {
+		if err = extractor.StoreXattrs(cfg.FileName, &data); err != nil {
+			return err
+		}
 	}
 
 	fmt.Printf("%s\n", json)
@@ -43,9 +88,61 @@ func run(cfg extractor.Cfg) error {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(runCheck(os.Args[2:]))
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "offsets":
+			runOffsets(os.Args[2:])
+			return
+		case "verify":
+			os.Exit(runVerify(os.Args[2:]))
+		case "salvage":
+			os.Exit(runSalvage(os.Args[2:]))
+		case "carve":
+			runCarve(os.Args[2:])
+			return
+		case "rewrite":
+			runRewrite(os.Args[2:])
+			return
+		case "stats":
+			runStats(os.Args[2:])
+			return
+		case "sizes":
+			runSizes(os.Args[2:])
+			return
+		case "rows":
+			runRows(os.Args[2:])
+			return
+		case "extract":
+			runExtract(os.Args[2:])
+			return
+		case "sample":
+			runSample(os.Args[2:])
+			return
+		case "profile":
+			runProfile(os.Args[2:])
+			return
+		case "scan":
+			os.Exit(runScan(os.Args[2:]))
+		}
+	}
+
 	cfg := extractor.Cfg{}
-	flag.StringVar(&cfg.FileName, "filename", "", "dump to read metadata of")
+	flag.StringVar(&cfg.FileName, "filename", "", "dump, split parts glob or list, http(s) URL, or s3://bucket/key (or s3://bucket/prefix/) to read metadata of")
 	flag.BoolVar(&cfg.Stdin, "stdin", false, "configure to read from stdin")
+	flag.BoolVar(&cfg.Xattrs, "xattrs", false, "serve metadata from user.pgdump.* xattrs when they match the file")
+	flag.BoolVar(&cfg.WriteXattrs, "write-xattrs", false, "store extracted metadata in user.pgdump.* xattrs")
+	flag.StringVar(&cfg.S3Endpoint, "s3-endpoint", "", "S3-compatible endpoint URL, addressed path-style")
+	flag.StringVar(&cfg.S3Region, "s3-region", "", "S3 signing region (default $AWS_REGION or us-east-1)")
+	flag.BoolVar(&cfg.Hash, "hash", false, "read the whole input and report SHA-256/SHA-512/CRC32C digests")
+	flag.BoolVar(&cfg.Lenient, "lenient", false, "report whatever can be read of a truncated dump, with what is missing")
 	flag.Parse()
 
 	if err := cfg.Validate(); err != nil {
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
 var ErrNotADump = errors.New("magic bytes not detected, not a dump?")
@@ -16,8 +18,37 @@ var ErrInvalidReadSize = errors.New("invalid read size")
 var ErrIntOverflow = errors.New("integer overflow")
 var ErrStringTooLarge = errors.New("string length too large")
 
+// FieldError is returned by NewMetadata when a header field can't be read.
+type FieldError struct {
+	Err error
+	// Field is the JSON name of the field that could not be read.
+	Field string
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
 const maxStringLen = 1 << 20
 
+// defaultCompression is zlib's default level, which formats before 1.2 were
+// always compressed at.
+const defaultCompression = -1
+
+// Archive format versions at which header fields were introduced.
+const (
+	versionWithRev             = (1 << 16) | (1 << 8)  // 1.1
+	versionWithCompression     = (1 << 16) | (2 << 8)  // 1.2
+	versionWithIntCompression  = (1 << 16) | (4 << 8)  // 1.4
+	versionWithServerVersions  = (1 << 16) | (10 << 8) // 1.10
+	versionWithCompressionSpec = (1 << 16) | (15 << 8) // 1.15
+	versionWithNewCompression  = (1 << 16) | (16 << 8) // 1.16
+)
+
 var (
 	maxInt = int(^uint(0) >> 1)
 	minInt = -maxInt - 1
@@ -76,6 +107,46 @@ func (m *Metadata) ArchiveVersion() int {
 	return (int(m.VMain) << 16) | (int(m.VMin) << 8) | int(m.VRev)
 }
 
+// compressionAlgorithms maps the compression byte of format 1.16+ headers to
+// algorithm names.
+var compressionAlgorithms = [...]string{"none", "gzip", "lz4", "zstd"}
+
+// CompressionAlgorithm returns the algorithm data blocks are compressed with:
+// "none", "gzip", "lz4" or "zstd". Formats before 1.15 only support gzip,
+// where any non-zero compression level enables it.
+func (m *Metadata) CompressionAlgorithm() string {
+	switch {
+	case m.CompressionSpec != nil:
+		algorithm, _, _ := strings.Cut(*m.CompressionSpec, ":")
+		if algorithm == "" {
+			return "none"
+		}
+		return algorithm
+	case m.ArchiveVersion() >= versionWithNewCompression:
+		if m.Compression >= 0 && m.Compression < len(compressionAlgorithms) {
+			return compressionAlgorithms[m.Compression]
+		}
+		return "unknown"
+	case m.Compression == 0:
+		return "none"
+	}
+
+	return "gzip"
+}
+
+// HasCreatedAt reports whether the header records the creation time, which
+// it does from 1.4, alongside the integer compression level.
+func (m *Metadata) HasCreatedAt() bool {
+	return m.ArchiveVersion() >= versionWithIntCompression
+}
+
+// CreatedAt returns the creation timestamp of the dump. pg_dump records the
+// broken-down local time of the dumping host with a zero-based month, so the
+// result is interpreted in the local time zone.
+func (m *Metadata) CreatedAt() time.Time {
+	return time.Date(m.TimeYear, time.Month(m.TimeMonth+1), m.TimeDay, m.TimeHour, m.TimeMin, m.TimeSec, 0, time.Local)
+}
+
 // ReadInt reads bytes from reader and operates in reverse byte order, returning an int64.
 func (m *Metadata) ReadInt(reader io.Reader) (int64, error) {
 	if m.IntSize == 0 || m.IntSize > 8 {
@@ -177,7 +248,7 @@ func readTimeFields(m *Metadata, readInt func(string) (int, error)) error {
 	if m.TimeMonth, err = readInt("timeMonth"); err != nil {
 		return err
 	}
-	yearOffset, err := readInt("timeYearOffset")
+	yearOffset, err := readInt("timeYear")
 	if err != nil {
 		return err
 	}
@@ -189,9 +260,18 @@ func readTimeFields(m *Metadata, readInt func(string) (int, error)) error {
 }
 
 // NewMetadata reads from reader, parsing out the pg_dump archive header format
-// into a Metadata struct.
-func NewMetadata(reader io.Reader) (Metadata, error) {
+// into a Metadata struct. When reader is a *bufio.Reader it is read directly,
+// leaving it positioned at the start of the TOC entries. On error, the fields
+// read so far are returned along with a *FieldError naming the field that
+// could not be read.
+func NewMetadata(reader io.Reader) (_ Metadata, err error) {
 	metadata := Metadata{}
+	field := "magic"
+	defer func() {
+		if err != nil {
+			err = &FieldError{Field: field, Err: err}
+		}
+	}()
 
 	r := bufio.NewReader(reader)
 	magicBytes := 5
@@ -207,28 +287,39 @@ func NewMetadata(reader io.Reader) (Metadata, error) {
 		return metadata, fmt.Errorf("%w, expected=PGDMP, got=%s not a dump?", ErrNotADump, metadata.Magic)
 	}
 
+	field = "vmain"
 	if metadata.VMain, err = ReadExactInt(r, 1); err != nil {
 		return metadata, err
 	}
+	field = "vmin"
 	if metadata.VMin, err = ReadExactInt(r, 1); err != nil {
 		return metadata, err
 	}
-	if metadata.VRev, err = ReadExactInt(r, 1); err != nil {
-		return metadata, err
+	field = "vrev"
+	if metadata.ArchiveVersion() >= versionWithRev {
+		if metadata.VRev, err = ReadExactInt(r, 1); err != nil {
+			return metadata, err
+		}
 	}
+	field = "intsize"
 	if metadata.IntSize, err = ReadExactInt(r, 1); err != nil {
 		return metadata, err
 	}
 	if metadata.IntSize == 0 || metadata.IntSize > 8 {
 		return metadata, fmt.Errorf("%w: intsize=%d", ErrInvalidIntSize, metadata.IntSize)
 	}
-	if metadata.OffSize, err = ReadExactInt(r, 1); err != nil {
+	field = "offsize"
+	if metadata.ArchiveVersion() < VersionWithOffsetFlags {
+		// Formats before 1.7 stored offsets as ints.
+		metadata.OffSize = metadata.IntSize
+	} else if metadata.OffSize, err = ReadExactInt(r, 1); err != nil {
 		return metadata, err
 	}
 	if metadata.OffSize == 0 || metadata.OffSize > 8 {
 		return metadata, fmt.Errorf("%w: offsize=%d", ErrInvalidOffSize, metadata.OffSize)
 	}
 
+	field = "format"
 	formatIdx, err := ReadExactInt(r, 1)
 	if err != nil {
 		return metadata, err
@@ -239,21 +330,14 @@ func NewMetadata(reader io.Reader) (Metadata, error) {
 	metadata.Format = formats[formatIdx]
 
 	readIntField := func(name string) (int, error) {
-		value, readErr := metadata.ReadInt(r)
-		if readErr != nil {
-			return 0, readErr
-		}
-		if value > int64(maxInt) || value < int64(minInt) {
-			return 0, fmt.Errorf("%w: %s=%d", ErrIntOverflow, name, value)
-		}
-		return int(value), nil
+		field = name
+		return metadata.readIntField(r, name)
 	}
 
 	// Archive format version 1.15+ (PostgreSQL 14+) changed compression from int to string.
 	// Version 1.16+ (PostgreSQL 16+) changed the format again - the compression algorithm
 	// is stored as a single byte indicator.
-	const versionWithCompressionSpec = (1 << 16) | (15 << 8) // 1.15
-	const versionWithNewCompression = (1 << 16) | (16 << 8)  // 1.16
+	field = "compression"
 	archiveVersion := metadata.ArchiveVersion()
 	switch {
 	case archiveVersion >= versionWithNewCompression:
@@ -268,23 +352,41 @@ func NewMetadata(reader io.Reader) (Metadata, error) {
 		if metadata.CompressionSpec, err = metadata.ReadString(r); err != nil {
 			return metadata, err
 		}
-	default:
+	case archiveVersion >= versionWithIntCompression:
 		// Older formats use an integer for compression level
 		if metadata.Compression, err = readIntField("compression"); err != nil {
 			return metadata, err
 		}
+	case archiveVersion >= versionWithCompression:
+		// Formats 1.2 and 1.3 store the compression level as a single byte.
+		level, readErr := ReadExactInt(r, 1)
+		if readErr != nil {
+			return metadata, readErr
+		}
+		metadata.Compression = int(level)
+	default:
+		// Format 1.0 and 1.1 archives were written at the default level.
+		metadata.Compression = defaultCompression
 	}
-	if err = readTimeFields(&metadata, readIntField); err != nil { //nolint:gocritic // reusing err is clearer here
-		return metadata, err
-	}
-	if metadata.DatabaseName, err = metadata.ReadString(r); err != nil {
-		return metadata, err
-	}
-	if metadata.RemoteVersion, err = metadata.ReadString(r); err != nil {
-		return metadata, err
+	// Format 1.4 also added the creation time and database name.
+	if archiveVersion >= versionWithIntCompression {
+		if err = readTimeFields(&metadata, readIntField); err != nil { //nolint:gocritic // reusing err is clearer here
+			return metadata, err
+		}
+		field = "database"
+		if metadata.DatabaseName, err = metadata.ReadString(r); err != nil {
+			return metadata, err
+		}
 	}
-	if metadata.PGDumpVersion, err = metadata.ReadString(r); err != nil {
-		return metadata, err
+	if archiveVersion >= versionWithServerVersions {
+		field = "remoteVersion"
+		if metadata.RemoteVersion, err = metadata.ReadString(r); err != nil {
+			return metadata, err
+		}
+		field = "pgDumpVersion"
+		if metadata.PGDumpVersion, err = metadata.ReadString(r); err != nil {
+			return metadata, err
+		}
 	}
 	if metadata.TOCCount, err = readIntField("toccount"); err != nil {
 		return metadata, err
@@ -336,3 +438,18 @@ func mapReadErr(err error) error {
 
 	return err
 }
+
+// CountingReader counts the bytes read from R, so that callers reading
+// through a bufio.Reader can tell their position in the archive.
+type CountingReader struct {
+	R io.Reader
+	// N is the number of bytes read so far.
+	N int64
+}
+
+func (c *CountingReader) Read(p []byte) (int, error) {
+	n, err := c.R.Read(p)
+	c.N += int64(n)
+
+	return n, err
+}
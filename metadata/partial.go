@@ -0,0 +1,118 @@
+package metadata
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"slices"
+)
+
+// headerField is the JSON name of a header field and the archive version
+// that added it.
+type headerField struct {
+	name  string
+	since int
+}
+
+// headerFields are the header fields, in archive order. Before version 1.7
+// the offset size isn't stored, and the version is read by vmin.
+var headerFields = [...]headerField{
+	{"magic", 0}, {"vmain", 0}, {"vmin", 0}, {"vrev", versionWithRev},
+	{"intsize", 0}, {"offsize", VersionWithOffsetFlags}, {"format", 0}, {"compression", versionWithCompression},
+	{"timeSec", versionWithIntCompression}, {"timeMin", versionWithIntCompression},
+	{"timeHour", versionWithIntCompression}, {"timeDay", versionWithIntCompression},
+	{"timeMonth", versionWithIntCompression}, {"timeYear", versionWithIntCompression},
+	{"timeIsDst", versionWithIntCompression}, {"database", versionWithIntCompression},
+	{"remoteVersion", versionWithServerVersions}, {"pgDumpVersion", versionWithServerVersions},
+	{"toccount", 0},
+}
+
+// missingFields returns the fields from field onwards that an archive of
+// version has. Until vmin is read the version isn't known, so every field
+// that any version has is listed.
+func missingFields(field string, version int) []string {
+	i := slices.IndexFunc(headerFields[:], func(f headerField) bool { return f.name == field })
+	if i < 0 {
+		return nil
+	}
+	versionKnown := i > slices.IndexFunc(headerFields[:], func(f headerField) bool { return f.name == "vmin" })
+
+	var missing []string
+	for _, f := range headerFields[i:] {
+		if !versionKnown || version >= f.since {
+			missing = append(missing, f.name)
+		}
+	}
+
+	return missing
+}
+
+// Partial holds whatever could be parsed of a possibly truncated archive,
+// along with what is missing and where parsing stopped.
+type Partial struct {
+	Metadata
+	// Entries are the TOC entries read in full.
+	Entries []TOCEntry `json:"-"`
+	// Missing are the header fields that could not be read, in archive order.
+	Missing []string `json:"missing,omitempty"`
+	// EntriesRead is the number of TOC entries read in full.
+	EntriesRead int `json:"tocEntriesRead"`
+	// MissingEntries is the number of TOC entries that could not be read.
+	MissingEntries int `json:"missingEntries,omitempty"`
+	// Offset is the position at which parsing stopped: the end of the TOC
+	// when it was read in full, or where the data ran out.
+	Offset int64 `json:"offset"`
+	// Err describes why parsing stopped early.
+	Err string `json:"error,omitempty"`
+}
+
+// Complete reports whether the header and every TOC entry were read.
+func (p *Partial) Complete() bool {
+	return p.Err == ""
+}
+
+// ReadPartial reads the header and TOC from reader like NewMetadata and
+// ReadTOC, but rather than failing on truncated or damaged input it returns
+// everything read up to that point. An error is only returned when reader
+// doesn't hold a dump at all. When reader is a *bufio.Reader it is read
+// directly and left positioned where parsing stopped, and Offset is left for
+// the caller, which knows the reader's position, to fill in.
+func ReadPartial(reader io.Reader) (*Partial, error) {
+	br, direct := reader.(*bufio.Reader)
+	counter := &CountingReader{R: reader}
+	if !direct {
+		br = bufio.NewReader(counter)
+	}
+	offset := func() int64 {
+		if direct {
+			return 0
+		}
+		return counter.N - int64(br.Buffered())
+	}
+
+	p := &Partial{}
+	meta, err := NewMetadata(br)
+	p.Metadata = meta
+	if err != nil {
+		p.Offset = offset()
+		p.Err = err.Error()
+
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field == "magic" {
+			return p, err
+		}
+		p.Missing = missingFields(fieldErr.Field, meta.ArchiveVersion())
+
+		return p, nil
+	}
+
+	p.Entries, err = meta.ReadTOC(br)
+	p.EntriesRead = len(p.Entries)
+	p.Offset = offset()
+	if err != nil {
+		p.Err = err.Error()
+		p.MissingEntries = max(meta.TOCCount-len(p.Entries), 0)
+	}
+
+	return p, nil
+}
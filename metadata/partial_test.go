@@ -0,0 +1,138 @@
+package metadata_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+func TestReadPartial(t *testing.T) {
+	t.Parallel()
+
+	built := dumptest.Archive{
+		Database: "shop",
+		VMin:     14,
+		Entries: []dumptest.Entry{
+			{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop"},
+			{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", Data: []byte("1\n")},
+			{Tag: "orders_pkey", Desc: "CONSTRAINT", Namespace: "public", Owner: "shop"},
+		},
+	}
+	data := built.Bytes()
+
+	full, err := metadata.ReadPartial(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tocEnd := full.Offset
+
+	testCases := []struct {
+		desc           string
+		size           int64
+		missing        []string
+		entriesRead    int
+		missingEntries int
+		database       string
+	}{
+		{desc: "complete", size: int64(len(data)), entriesRead: 3, database: "shop"},
+		{desc: "in compression", size: 13, missing: []string{
+			"compression", "timeSec", "timeMin", "timeHour", "timeDay", "timeMonth", "timeYear", "timeIsDst",
+			"database", "remoteVersion", "pgDumpVersion", "toccount",
+		}},
+		{desc: "after database", size: 60, missing: []string{"remoteVersion", "pgDumpVersion", "toccount"}, database: "shop"},
+		{desc: "in TOC", size: tocEnd - 3, entriesRead: 2, missingEntries: 1, database: "shop"},
+	}
+	for _, tC := range testCases {
+		p, err := metadata.ReadPartial(bytes.NewReader(data[:tC.size]))
+		if err != nil {
+			t.Fatalf("%s: %v", tC.desc, err)
+		}
+
+		if !reflect.DeepEqual(p.Missing, tC.missing) {
+			t.Errorf("%s: expected=%v, got=%v", tC.desc, tC.missing, p.Missing)
+		}
+		if p.EntriesRead != tC.entriesRead || len(p.Entries) != tC.entriesRead || p.MissingEntries != tC.missingEntries {
+			t.Errorf("%s: expected read=%d missing=%d, got read=%d missing=%d",
+				tC.desc, tC.entriesRead, tC.missingEntries, p.EntriesRead, p.MissingEntries)
+		}
+		if p.Complete() != (tC.size == int64(len(data))) {
+			t.Errorf("%s: unexpected complete=%v, err=%q", tC.desc, p.Complete(), p.Err)
+		}
+		if tC.database != "" && (p.DatabaseName == nil || *p.DatabaseName != tC.database) {
+			t.Errorf("%s: expected=%s, got=%v", tC.desc, tC.database, p.DatabaseName)
+		}
+		if tC.size < tocEnd && p.Offset != tC.size {
+			t.Errorf("%s: expected offset=%d, got=%d", tC.desc, tC.size, p.Offset)
+		}
+	}
+}
+
+func TestReadPartialOldVersion(t *testing.T) {
+	t.Parallel()
+
+	// Version 1.3 has no offset size, creation time, database or server
+	// versions in its header.
+	data := (&dumptest.Archive{VMin: 3}).Bytes()
+
+	testCases := []struct {
+		desc    string
+		size    int
+		missing []string
+	}{
+		{desc: "after vrev", size: 8, missing: []string{"intsize", "format", "compression", "toccount"}},
+		{desc: "in compression", size: 10, missing: []string{"compression", "toccount"}},
+		{desc: "before vmin", size: 6, missing: []string{
+			"vmin", "vrev", "intsize", "offsize", "format", "compression",
+			"timeSec", "timeMin", "timeHour", "timeDay", "timeMonth", "timeYear", "timeIsDst",
+			"database", "remoteVersion", "pgDumpVersion", "toccount",
+		}},
+	}
+	for _, tC := range testCases {
+		p, err := metadata.ReadPartial(bytes.NewReader(data[:tC.size]))
+		if err != nil {
+			t.Fatalf("%s: %v", tC.desc, err)
+		}
+		if !reflect.DeepEqual(p.Missing, tC.missing) {
+			t.Errorf("%s: expected=%v, got=%v", tC.desc, tC.missing, p.Missing)
+		}
+	}
+}
+
+func TestReadPartialNotADump(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc string
+		data []byte
+		err  error
+	}{
+		{desc: "not a dump", data: []byte("hello, world"), err: metadata.ErrNotADump},
+		{desc: "empty", data: nil, err: metadata.ErrNeedMoreData},
+	}
+	for _, tC := range testCases {
+		if _, err := metadata.ReadPartial(bytes.NewReader(tC.data)); !errors.Is(err, tC.err) {
+			t.Errorf("%s: expected=%v, got=%v", tC.desc, tC.err, err)
+		}
+	}
+}
+
+func TestNewMetadataFieldError(t *testing.T) {
+	t.Parallel()
+
+	data := (&dumptest.Archive{VMin: 16}).Bytes()
+
+	_, err := metadata.NewMetadata(bufio.NewReader(bytes.NewReader(data[:12])))
+
+	var fieldErr *metadata.FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "timeSec" {
+		t.Errorf("expected=timeSec, got=%v", err)
+	}
+	if !errors.Is(err, metadata.ErrNeedMoreData) {
+		t.Errorf("expected=%v, got=%v", metadata.ErrNeedMoreData, err)
+	}
+}
@@ -0,0 +1,355 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+var ErrInvalidTOC = errors.New("invalid TOC entry")
+var ErrInvalidOffset = errors.New("invalid data offset")
+
+// Data offset states of a TOC entry, as recorded by the custom format.
+const (
+	// OffsetPosNotSet means the entry has data but its offset was not
+	// recorded, as happens when the archive is written to a pipe.
+	OffsetPosNotSet = 1
+	// OffsetPosSet means DataOffset holds the position of the entry's data.
+	OffsetPosSet = 2
+	// OffsetNoData means the entry has no data.
+	OffsetNoData = 3
+)
+
+// sections maps section index to section name for TOC entries.
+var sections = [...]string{"UNKNOWN", "NONE", "PRE-DATA", "DATA", "POST-DATA"}
+
+// Archive format versions at which TOC entry fields were introduced.
+const (
+	versionWithCopyStmt   = (1 << 16) | (3 << 8)  // 1.3
+	versionWithDeps       = (1 << 16) | (5 << 8)  // 1.5
+	versionWithNamespace  = (1 << 16) | (6 << 8)  // 1.6
+	versionWithTableOID   = (1 << 16) | (8 << 8)  // 1.8
+	versionWithOIDsFlag   = (1 << 16) | (9 << 8)  // 1.9
+	versionWithTablespace = (1 << 16) | (10 << 8) // 1.10
+	versionWithSection    = (1 << 16) | (11 << 8) // 1.11
+	versionWithTableAM    = (1 << 16) | (14 << 8) // 1.14
+	versionWithTOCRelKind = (1 << 16) | (16 << 8) // 1.16
+)
+
+// VersionWithOffsetFlags is the archive version from which custom format TOC
+// entries end with a flag byte and a fixed-size data offset, which can be
+// patched in place.
+const VersionWithOffsetFlags = (1 << 16) | (7 << 8) // 1.7
+
+// MaxTOCEntriesPreallocated bounds the up-front allocation for a TOC whose
+// count may be corrupt.
+const MaxTOCEntriesPreallocated = 1024
+
+// TOCEntry represents an entry in the table of contents of the dump.
+type TOCEntry struct {
+	// TableOID is the OID of the catalog the object belongs to (format >= 1.8).
+	TableOID string `json:"tableOid,omitempty"`
+	// OID is the OID of the object.
+	OID string `json:"oid"`
+	// Tag is the name of the object.
+	Tag string `json:"tag"`
+	// Desc is the type of the object, e.g. TABLE or TABLE DATA.
+	Desc string `json:"desc"`
+	// Section is the section the entry is restored in.
+	Section string `json:"section"`
+	// Defn is the SQL creating the object.
+	Defn string `json:"defn,omitempty"`
+	// DropStmt is the SQL dropping the object.
+	DropStmt string `json:"dropStmt,omitempty"`
+	// CopyStmt is the COPY statement the entry's data is restored with.
+	CopyStmt string `json:"copyStmt,omitempty"`
+	// Namespace is the schema of the object.
+	Namespace string `json:"namespace,omitempty"`
+	// Tablespace is the tablespace of the object.
+	Tablespace string `json:"tablespace,omitempty"`
+	// TableAM is the table access method of the object (format >= 1.14).
+	TableAM string `json:"tableAm,omitempty"`
+	// Owner is the owner of the object.
+	Owner string `json:"owner"`
+	// FileName is the file holding the entry's data, for formats that keep
+	// data outside the TOC.
+	FileName string `json:"fileName,omitempty"`
+	// Dependencies are the dump IDs the entry depends on.
+	Dependencies []int `json:"dependencies,omitempty"`
+	// DumpID identifies the entry and its data blocks.
+	DumpID int `json:"dumpId"`
+	// RelKind is the relkind of the object (format >= 1.16).
+	RelKind int `json:"relKind,omitempty"`
+	// DataOffset is the position of the entry's data block, when DataState
+	// is OffsetPosSet.
+	DataOffset int64 `json:"dataOffset,omitempty"`
+	// DataState is one of the Offset* constants (custom format).
+	DataState int `json:"dataState,omitempty"`
+	// HadDumper records whether the entry had a data dumper.
+	HadDumper bool `json:"hadDumper"`
+}
+
+// HasData reports whether the entry has data in the archive.
+func (e *TOCEntry) HasData() bool {
+	if e.DataState != 0 {
+		return e.DataState != OffsetNoData
+	}
+
+	return e.HadDumper
+}
+
+// QualifiedName returns the schema-qualified name of the object.
+func (e *TOCEntry) QualifiedName() string {
+	if e.Namespace == "" {
+		return e.Tag
+	}
+
+	return e.Namespace + "." + e.Tag
+}
+
+// legacySection classifies entries of archives from before format 1.11, which
+// didn't record sections, by their type as pg_restore does.
+func legacySection(desc string) string {
+	switch desc {
+	case "COMMENT", "ACL", "ACL LANGUAGE":
+		return "NONE"
+	case "TABLE DATA", "BLOBS", "BLOB COMMENTS":
+		return "DATA"
+	case "CONSTRAINT", "CHECK CONSTRAINT", "FK CONSTRAINT", "INDEX", "RULE", "TRIGGER":
+		return "POST-DATA"
+	}
+
+	return "PRE-DATA"
+}
+
+// ReadTOC reads the TOCCount entries of the table of contents from reader,
+// which must be positioned directly after the header.
+func (m *Metadata) ReadTOC(reader io.Reader) ([]TOCEntry, error) {
+	if m.TOCCount < 0 {
+		return nil, fmt.Errorf("%w: toccount=%d", ErrInvalidTOC, m.TOCCount)
+	}
+
+	entries := make([]TOCEntry, 0, min(m.TOCCount, MaxTOCEntriesPreallocated))
+	for i := 0; i < m.TOCCount; i++ {
+		entry, err := m.ReadTOCEntry(reader)
+		if err != nil {
+			return entries, fmt.Errorf("err reading TOC entry %d: %w", i, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ReadTOCEntry reads a single TOC entry from reader.
+func (m *Metadata) ReadTOCEntry(reader io.Reader) (TOCEntry, error) {
+	entry := TOCEntry{}
+	version := m.ArchiveVersion()
+
+	dumpID, err := m.readIntField(reader, "dumpId")
+	if err != nil {
+		return entry, err
+	}
+	if dumpID <= 0 {
+		return entry, fmt.Errorf("%w: dumpId=%d", ErrInvalidTOC, dumpID)
+	}
+	entry.DumpID = dumpID
+
+	hadDumper, err := m.readIntField(reader, "hadDumper")
+	if err != nil {
+		return entry, err
+	}
+	entry.HadDumper = hadDumper != 0
+
+	if version >= versionWithTableOID {
+		if entry.TableOID, err = m.readStringField(reader); err != nil {
+			return entry, err
+		}
+	}
+	if entry.OID, err = m.readStringField(reader); err != nil {
+		return entry, err
+	}
+	if entry.Tag, err = m.readStringField(reader); err != nil {
+		return entry, err
+	}
+	if entry.Desc, err = m.readStringField(reader); err != nil {
+		return entry, err
+	}
+
+	if version >= versionWithSection {
+		section, readErr := m.readIntField(reader, "section")
+		if readErr != nil {
+			return entry, readErr
+		}
+		if section < 0 || section >= len(sections) {
+			return entry, fmt.Errorf("%w: section=%d", ErrInvalidTOC, section)
+		}
+		entry.Section = sections[section]
+	} else {
+		entry.Section = legacySection(entry.Desc)
+	}
+
+	if entry.Defn, err = m.readStringField(reader); err != nil {
+		return entry, err
+	}
+	if entry.DropStmt, err = m.readStringField(reader); err != nil {
+		return entry, err
+	}
+	if version >= versionWithCopyStmt {
+		if entry.CopyStmt, err = m.readStringField(reader); err != nil {
+			return entry, err
+		}
+	}
+	if version >= versionWithNamespace {
+		if entry.Namespace, err = m.readStringField(reader); err != nil {
+			return entry, err
+		}
+	}
+	if version >= versionWithTablespace {
+		if entry.Tablespace, err = m.readStringField(reader); err != nil {
+			return entry, err
+		}
+	}
+	if version >= versionWithTableAM {
+		if entry.TableAM, err = m.readStringField(reader); err != nil {
+			return entry, err
+		}
+	}
+	if version >= versionWithTOCRelKind {
+		if entry.RelKind, err = m.readIntField(reader, "relkind"); err != nil {
+			return entry, err
+		}
+	}
+	if entry.Owner, err = m.readStringField(reader); err != nil {
+		return entry, err
+	}
+	if version >= versionWithOIDsFlag {
+		// WITH OIDS is no longer supported; the flag is read and ignored.
+		if _, err = m.ReadString(reader); err != nil {
+			return entry, err
+		}
+	}
+	if version >= versionWithDeps {
+		if entry.Dependencies, err = m.readDependencies(reader); err != nil {
+			return entry, err
+		}
+	}
+
+	if err = m.readExtraTOC(reader, &entry); err != nil {
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// readExtraTOC reads the format-specific trailer of a TOC entry.
+func (m *Metadata) readExtraTOC(reader io.Reader, entry *TOCEntry) error {
+	var err error
+
+	switch m.Format {
+	case "CUSTOM":
+		entry.DataState, entry.DataOffset, err = m.ReadOffset(reader)
+		if err != nil {
+			return err
+		}
+		if m.ArchiveVersion() < VersionWithOffsetFlags {
+			// Formats before 1.7 also recorded the data size, which is unused.
+			if _, err = m.ReadInt(reader); err != nil {
+				return err
+			}
+		}
+	case "DIRECTORY", "TAR", "FILE":
+		if entry.FileName, err = m.readStringField(reader); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadOffset reads a data offset, returning its state and position.
+func (m *Metadata) ReadOffset(reader io.Reader) (state int, offset int64, err error) {
+	if m.ArchiveVersion() < VersionWithOffsetFlags {
+		pos, readErr := m.ReadInt(reader)
+		if readErr != nil {
+			return 0, 0, readErr
+		}
+		switch {
+		case pos < 0:
+			return OffsetPosNotSet, 0, nil
+		case pos == 0:
+			return OffsetNoData, 0, nil
+		}
+		return OffsetPosSet, pos, nil
+	}
+
+	flag, err := ReadExactInt(reader, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if flag < OffsetPosNotSet || flag > OffsetNoData {
+		return 0, 0, fmt.Errorf("%w: flag=%d", ErrInvalidOffset, flag)
+	}
+
+	buf := make([]byte, int(m.OffSize))
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return 0, 0, mapReadErr(err)
+	}
+
+	var val uint64
+	for i := int(m.OffSize) - 1; i >= 0; i-- {
+		val = (val << 8) + uint64(buf[i])
+	}
+	if val > uint64(math.MaxInt64) {
+		return 0, 0, fmt.Errorf("%w: %d", ErrIntOverflow, val)
+	}
+
+	return int(flag), int64(val), nil
+}
+
+// readDependencies reads the NULL-terminated list of dependency dump IDs.
+func (m *Metadata) readDependencies(reader io.Reader) ([]int, error) {
+	var deps []int
+
+	for {
+		dep, err := m.ReadString(reader)
+		if err != nil {
+			return nil, err
+		}
+		if dep == nil {
+			return deps, nil
+		}
+
+		id, err := strconv.Atoi(*dep)
+		if err != nil {
+			return nil, fmt.Errorf("%w: dependency=%q", ErrInvalidTOC, *dep)
+		}
+		deps = append(deps, id)
+	}
+}
+
+// readIntField reads an int, checking it fits in an int.
+func (m *Metadata) readIntField(reader io.Reader, name string) (int, error) {
+	value, err := m.ReadInt(reader)
+	if err != nil {
+		return 0, err
+	}
+	if value > int64(maxInt) || value < int64(minInt) {
+		return 0, fmt.Errorf("%w: %s=%d", ErrIntOverflow, name, value)
+	}
+
+	return int(value), nil
+}
+
+// readStringField reads a string, treating NULL as empty.
+func (m *Metadata) readStringField(reader io.Reader) (string, error) {
+	val, err := m.ReadString(reader)
+	if err != nil || val == nil {
+		return "", err
+	}
+
+	return *val, nil
+}
@@ -0,0 +1,179 @@
+package metadata_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/internal/dumptest"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+func TestReadTOC(t *testing.T) {
+	t.Parallel()
+
+	for _, vmin := range []uint8{13, 14, 15, 16} {
+		built := dumptest.Archive{
+			VMin: vmin,
+			Entries: []dumptest.Entry{
+				{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop"},
+				{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", Data: []byte("1\n"), Deps: []int{1}},
+			},
+		}
+
+		r := bufio.NewReader(bytes.NewReader(built.Bytes()))
+		meta, err := metadata.NewMetadata(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entries, err := meta.ReadTOC(r)
+		if err != nil {
+			t.Fatalf("vmin=%d: %v", vmin, err)
+		}
+
+		if len(entries) != 2 {
+			t.Fatalf("vmin=%d: expected=%d, got=%d", vmin, 2, len(entries))
+		}
+		if !reflect.DeepEqual(entries[1].Dependencies, []int{1}) || !entries[1].HasData() || entries[0].HasData() {
+			t.Errorf("vmin=%d: unexpected entries: %+v", vmin, entries)
+		}
+		if vmin >= 16 && entries[0].RelKind != 'r' {
+			t.Errorf("vmin=%d: expected relkind, got=%d", vmin, entries[0].RelKind)
+		}
+		if r.Buffered() != len(built.DataBlocks()[1]) {
+			t.Errorf("vmin=%d: expected reader at the data, %d bytes left", vmin, r.Buffered())
+		}
+	}
+}
+
+func TestReadTOCEntryInvalidDumpID(t *testing.T) {
+	t.Parallel()
+
+	meta := metadata.Metadata{IntSize: 4, OffSize: 8, VMain: 1, VMin: 14, Format: "CUSTOM"}
+	_, err := meta.ReadTOCEntry(bytes.NewReader(encodeInt(0, 4)))
+	if !errors.Is(err, metadata.ErrInvalidTOC) {
+		t.Errorf("expected=%v, got=%v", metadata.ErrInvalidTOC, err)
+	}
+}
+
+func TestReadOffset(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		err    error
+		desc   string
+		input  []byte
+		vmin   uint8
+		state  int
+		offset int64
+	}{
+		{desc: "set", vmin: 14, input: dumptest.Offset(2, 1234), state: metadata.OffsetPosSet, offset: 1234},
+		{desc: "not set", vmin: 14, input: dumptest.Offset(1, 0), state: metadata.OffsetPosNotSet},
+		{desc: "no data", vmin: 14, input: dumptest.Offset(3, 0), state: metadata.OffsetNoData},
+		{desc: "invalid flag", vmin: 14, input: dumptest.Offset(9, 0), err: metadata.ErrInvalidOffset},
+		{desc: "truncated", vmin: 14, input: dumptest.Offset(2, 0)[:4], err: metadata.ErrNeedMoreData},
+		{desc: "pre-1.7 set", vmin: 6, input: encodeInt(99, 4), state: metadata.OffsetPosSet, offset: 99},
+		{desc: "pre-1.7 not set", vmin: 6, input: encodeInt(-1, 4), state: metadata.OffsetPosNotSet},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			meta := metadata.Metadata{IntSize: 4, OffSize: 8, VMain: 1, VMin: tC.vmin}
+			state, offset, err := meta.ReadOffset(bytes.NewReader(tC.input))
+			if !errors.Is(err, tC.err) {
+				t.Fatalf("expected=%v, got=%v", tC.err, err)
+			}
+			if state != tC.state || offset != tC.offset {
+				t.Errorf("expected=%d/%d, got=%d/%d", tC.state, tC.offset, state, offset)
+			}
+		})
+	}
+}
+
+func TestCompressionAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc string
+		meta metadata.Metadata
+		exp  string
+	}{
+		{desc: "default level", meta: metadata.Metadata{VMain: 1, VMin: 13, Compression: -1}, exp: "gzip"},
+		{desc: "level 0", meta: metadata.Metadata{VMain: 1, VMin: 13}, exp: "none"},
+		{desc: "spec", meta: metadata.Metadata{VMain: 1, VMin: 15, CompressionSpec: strPtr("gzip:9")}, exp: "gzip"},
+		{desc: "algorithm byte", meta: metadata.Metadata{VMain: 1, VMin: 16, Compression: 3}, exp: "zstd"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tC.meta.CompressionAlgorithm(); got != tC.exp {
+				t.Errorf("expected=%s, got=%s", tC.exp, got)
+			}
+		})
+	}
+}
+
+func TestReadLegacyFileFormat(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc     string
+		vmin     uint8
+		offSize  uint8
+		database bool
+		versions bool
+	}{
+		{desc: "1.12", vmin: 12, offSize: dumptest.OffSize, database: true, versions: true},
+		{desc: "1.10 without sections", vmin: 10, offSize: dumptest.OffSize, database: true, versions: true},
+		{desc: "1.9 without server versions", vmin: 9, offSize: dumptest.OffSize, database: true},
+		{desc: "1.6 without offset size", vmin: 6, offSize: dumptest.IntSize, database: true},
+		{desc: "1.3 without timestamp", vmin: 3, offSize: dumptest.IntSize},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			built := dumptest.Archive{
+				VMin:          tC.vmin,
+				Format:        2, // FILE
+				Compression:   5,
+				Database:      "legacy",
+				RemoteVersion: "8.4.22",
+				PGDumpVersion: "8.4.22",
+				Entries: []dumptest.Entry{
+					{Tag: "orders", Desc: "TABLE", Namespace: "public", Owner: "shop", Section: dumptest.SectionPreData},
+					{Tag: "orders", Desc: "TABLE DATA", Namespace: "public", Owner: "shop", FileName: "2.dat.gz", Section: dumptest.SectionData},
+					{Tag: "orders_pkey", Desc: "INDEX", Namespace: "public", Owner: "shop", Section: dumptest.SectionPostData},
+				},
+			}
+
+			r := bufio.NewReader(bytes.NewReader(built.Bytes()))
+			meta, err := metadata.NewMetadata(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if meta.Format != "FILE" || meta.Compression != 5 || meta.OffSize != tC.offSize || meta.TOCCount != 3 {
+				t.Errorf("unexpected header: %+v", meta)
+			}
+			if (meta.DatabaseName != nil) != tC.database || (meta.PGDumpVersion != nil) != tC.versions {
+				t.Errorf("unexpected header: %+v", meta)
+			}
+
+			entries, err := meta.ReadTOC(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entries[1].FileName != "2.dat.gz" || entries[1].Section != "DATA" || entries[2].Section != "POST-DATA" {
+				t.Errorf("unexpected entries: %+v", entries)
+			}
+			if r.Buffered() != 0 {
+				t.Errorf("expected the TOC to end the archive, %d bytes left", r.Buffered())
+			}
+		})
+	}
+}
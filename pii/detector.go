@@ -0,0 +1,238 @@
+// Package pii scans table data for personal data such as email addresses,
+// phone numbers, card numbers, national IDs and IP addresses.
+package pii
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrUnknownDetector = errors.New("unknown detector")
+	ErrInvalidDetector = errors.New("invalid detector")
+)
+
+// Detector finds one kind of personal data in values: candidates matching a
+// pattern that, for detectors with a checksum or structure to check, are
+// then validated to keep false positives down.
+type Detector struct {
+	// Name names the detector in reports.
+	Name    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+// Count returns the number of matches in value.
+func (d *Detector) Count(value string) int {
+	n := 0
+	for _, match := range d.pattern.FindAllString(value, -1) {
+		if d.valid == nil || d.valid(match) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// NewDetector returns a detector named name counting matches of the regular
+// expression pattern, in RE2 syntax.
+func NewDetector(name, pattern string) (*Detector, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: missing name", ErrInvalidDetector)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidDetector, name, err)
+	}
+
+	return &Detector{Name: name, pattern: re}, nil
+}
+
+// ParseDetector parses a detector written as name=pattern.
+func ParseDetector(spec string) (*Detector, error) {
+	name, pattern, ok := strings.Cut(spec, "=")
+	if !ok {
+		return nil, fmt.Errorf("%w: expected name=pattern, got %q", ErrInvalidDetector, spec)
+	}
+
+	return NewDetector(name, pattern)
+}
+
+// Names of the built-in detectors.
+const (
+	Email = "email"
+	Phone = "phone"
+	Card  = "card"
+	SSN   = "ssn"
+	NINO  = "nino"
+	IPv4  = "ipv4"
+	IPv6  = "ipv6"
+)
+
+// builtin are the built-in detectors, in report order.
+var builtin = []*Detector{
+	{
+		Name:    Email,
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	{
+		// International numbers, or national ones grouped with spaces,
+		// hyphens or a bracketed area code. Bare digit runs are left alone,
+		// as they are more often IDs than phone numbers.
+		Name:    Phone,
+		pattern: regexp.MustCompile(`\+\d{1,3}[ -]?(?:\(\d{1,5}\)[ -]?)?\d{2,5}(?:[ -]?\d{2,5}){1,3}|(?:\(\d{2,5}\) ?|\b\d{2,5}[ -])\d{3,4}[ -]?\d{3,4}\b`),
+		valid:   phoneNumber,
+	},
+	{
+		Name:    Card,
+		pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid:   cardNumber,
+	},
+	{
+		// US Social Security numbers.
+		Name:    SSN,
+		pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		valid:   socialSecurityNumber,
+	},
+	{
+		// UK National Insurance numbers.
+		Name:    NINO,
+		pattern: regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`),
+		valid:   insuranceNumber,
+	},
+	{
+		Name:    IPv4,
+		pattern: regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`),
+		valid:   ipv4Address,
+	},
+	{
+		Name:    IPv6,
+		pattern: regexp.MustCompile(`[0-9A-Fa-f]*:[0-9A-Fa-f]*:[0-9A-Fa-f:]*(?:\d{1,3}(?:\.\d{1,3}){3})?`),
+		valid:   ipv6Address,
+	},
+}
+
+// Builtin returns the built-in detectors.
+func Builtin() []*Detector {
+	return append([]*Detector(nil), builtin...)
+}
+
+// Lookup returns the built-in detectors with names, in that order.
+func Lookup(names []string) ([]*Detector, error) {
+	detectors := make([]*Detector, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, d := range builtin {
+			if d.Name == name {
+				detectors = append(detectors, d)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownDetector, name)
+		}
+	}
+
+	return detectors, nil
+}
+
+// digitsOf returns the digits of s, dropping separators.
+func digitsOf(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// phoneNumber reports whether s has as many digits as an E.164 number with
+// its area code.
+func phoneNumber(s string) bool {
+	n := len(digitsOf(s))
+	return n >= 9 && n <= 15
+}
+
+// cardNumber reports whether s is a payment card number: 13 to 19 digits
+// from a major network's range, passing the Luhn check.
+func cardNumber(s string) bool {
+	digits := digitsOf(s)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	switch digits[0] {
+	case '2', '3', '4', '5', '6':
+	default:
+		return false
+	}
+
+	return luhn(digits)
+}
+
+// luhn reports whether the digits pass the Luhn checksum.
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// socialSecurityNumber reports whether s, as AAA-GG-SSSS, has an area,
+// group and serial that are ever issued.
+func socialSecurityNumber(s string) bool {
+	area, group, serial := s[0:3], s[4:6], s[7:11]
+
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// insuranceNumber reports whether s has a National Insurance number prefix
+// that is ever issued.
+func insuranceNumber(s string) bool {
+	switch s[:2] {
+	case "BG", "GB", "KN", "NK", "NT", "TN", "ZZ":
+		return false
+	}
+
+	return true
+}
+
+func ipv4Address(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	return err == nil && addr.Is4()
+}
+
+// ipv6Address reports whether s is an IPv6 address with at least two groups
+// of digits, so that :: and C++ style names like std::string are not taken
+// for addresses.
+func ipv6Address(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	if err != nil || !addr.Is6() {
+		return false
+	}
+
+	groups := 0
+	for _, group := range strings.Split(s, ":") {
+		if group != "" {
+			groups++
+		}
+	}
+
+	return groups >= 2
+}
@@ -0,0 +1,79 @@
+package pii_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/pii"
+)
+
+func TestBuiltinDetectors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		desc     string
+		detector string
+		value    string
+		expected int
+	}{
+		{desc: "email", detector: pii.Email, value: "mail alice@example.com or bob.smith+x@mail.example.co.uk", expected: 2},
+		{desc: "not an email", detector: pii.Email, value: "@example.com user@localhost", expected: 0},
+		{desc: "international phone", detector: pii.Phone, value: "call +44 20 7946 0958", expected: 1},
+		{desc: "national phone", detector: pii.Phone, value: "(555) 123-4567 or 020 7946 0958", expected: 2},
+		{desc: "not a phone", detector: pii.Phone, value: "id 20210603 on 2021-06-03 18:53:33 at 192.168.1.10", expected: 0},
+		{desc: "card", detector: pii.Card, value: "4111 1111 1111 1111 and 5500-0000-0000-0004", expected: 2},
+		{desc: "card failing Luhn", detector: pii.Card, value: "4111 1111 1111 1112", expected: 0},
+		{desc: "card outside network ranges", detector: pii.Card, value: "1234567812345670", expected: 0},
+		{desc: "ssn", detector: pii.SSN, value: "SSN 123-45-6789", expected: 1},
+		{desc: "never issued ssn", detector: pii.SSN, value: "000-12-3456 666-12-3456 900-12-3456 123-00-4567 123-45-0000", expected: 0},
+		{desc: "nino", detector: pii.NINO, value: "NI AB 12 34 56 C and JG123456A", expected: 2},
+		{desc: "never issued nino", detector: pii.NINO, value: "GB123456A QQ123456C", expected: 0},
+		{desc: "ipv4", detector: pii.IPv4, value: "from 192.168.1.10 via 10.0.0.1", expected: 2},
+		{desc: "not ipv4", detector: pii.IPv4, value: "version 1.2.300.4", expected: 0},
+		{desc: "ipv6", detector: pii.IPv6, value: "from 2001:db8::8a2e:370:7334 and fe80::1", expected: 2},
+		{desc: "not ipv6", detector: pii.IPv6, value: "std::string at 18:53:33 ::", expected: 0},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			detectors, err := pii.Lookup([]string{tC.detector})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := detectors[0].Count(tC.value); got != tC.expected {
+				t.Errorf("expected=%d, got=%d", tC.expected, got)
+			}
+		})
+	}
+}
+
+func TestLookupUnknown(t *testing.T) {
+	t.Parallel()
+
+	if _, err := pii.Lookup([]string{pii.Email, "passport"}); !errors.Is(err, pii.ErrUnknownDetector) {
+		t.Errorf("expected=%v, got=%v", pii.ErrUnknownDetector, err)
+	}
+}
+
+func TestParseDetector(t *testing.T) {
+	t.Parallel()
+
+	d, err := pii.ParseDetector(`customer=CUST-\d{6}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "customer" {
+		t.Errorf("expected=%s, got=%s", "customer", d.Name)
+	}
+	if got := d.Count("CUST-000123, CUST-12"); got != 1 {
+		t.Errorf("expected=%d, got=%d", 1, got)
+	}
+
+	for _, spec := range []string{`customer`, `=\d+`, `bad=(`} {
+		if _, err := pii.ParseDetector(spec); !errors.Is(err, pii.ErrInvalidDetector) {
+			t.Errorf("%s: expected=%v, got=%v", spec, pii.ErrInvalidDetector, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package pii
+
+import (
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+)
+
+// DefaultExamples is the number of example offsets kept per finding by
+// default.
+const DefaultExamples = 5
+
+// Finding counts the matches of one detector in one column.
+type Finding struct {
+	// Column is the column name, from the COPY statement.
+	Column string `json:"column"`
+	// Detector is the name of the detector.
+	Detector string `json:"detector"`
+	// Hits is the number of matches.
+	Hits int64 `json:"hits"`
+	// Offsets are the offsets in the table's decompressed COPY data of the
+	// first rows with matches. Matched values are never reported, so the
+	// report can be shared without leaking what it found.
+	Offsets []int64 `json:"offsets"`
+}
+
+// Table is the result of scanning a table's data.
+type Table struct {
+	// Namespace is the schema of the table.
+	Namespace string `json:"namespace,omitempty"`
+	// Tag is the name of the table.
+	Tag string `json:"tag"`
+	// Rows is the number of rows scanned.
+	Rows int64 `json:"rows"`
+	// Hits is the number of matches across columns and detectors.
+	Hits int64 `json:"hits"`
+	// Findings are the columns with matches, by column then detector.
+	Findings []Finding `json:"findings"`
+}
+
+// Report is the result of scanning a dump.
+type Report struct {
+	// Tables are the tables scanned, including those without matches.
+	Tables []Table `json:"tables"`
+	// Hits is the number of matches across tables.
+	Hits int64 `json:"hits"`
+}
+
+// Add adds the result of scanning a table.
+func (r *Report) Add(t Table) {
+	r.Tables = append(r.Tables, t)
+	r.Hits += t.Hits
+}
+
+// Clean reports whether no detector matched.
+func (r *Report) Clean() bool {
+	return r.Hits == 0
+}
+
+// Scanner runs detectors over the rows of a table.
+type Scanner struct {
+	detectors []*Detector
+	columns   []string
+	examples  int
+	rows      int64
+	// findings holds a finding per column and detector, created on the
+	// first match.
+	findings [][]*Finding
+}
+
+// NewScanner returns a Scanner running detectors over the values of a table
+// with columns, keeping up to examples offsets per finding. Without columns,
+// they are named column1, column2, …
+func NewScanner(detectors []*Detector, columns []string, examples int) *Scanner {
+	return &Scanner{detectors: detectors, columns: columns, examples: examples}
+}
+
+// Scan scans a row found at offset in the table's data.
+func (s *Scanner) Scan(row copydata.Row, offset int64) {
+	s.rows++
+
+	for len(s.findings) < len(row) {
+		s.findings = append(s.findings, make([]*Finding, len(s.detectors)))
+	}
+
+	for i, field := range row {
+		if field.Null || field.Value == "" {
+			continue
+		}
+
+		for j, d := range s.detectors {
+			n := d.Count(field.Value)
+			if n == 0 {
+				continue
+			}
+
+			f := s.findings[i][j]
+			if f == nil {
+				f = &Finding{Column: s.columnName(i), Detector: d.Name, Offsets: []int64{}}
+				s.findings[i][j] = f
+			}
+			f.Hits += int64(n)
+			if len(f.Offsets) < s.examples {
+				f.Offsets = append(f.Offsets, offset)
+			}
+		}
+	}
+}
+
+func (s *Scanner) columnName(i int) string {
+	if i < len(s.columns) {
+		return s.columns[i]
+	}
+
+	return copydata.ColumnName(i)
+}
+
+// Table returns the result of the rows scanned so far.
+func (s *Scanner) Table() Table {
+	t := Table{Rows: s.rows, Findings: []Finding{}}
+	for _, column := range s.findings {
+		for _, f := range column {
+			if f != nil {
+				t.Findings = append(t.Findings, *f)
+				t.Hits += f.Hits
+			}
+		}
+	}
+
+	return t
+}
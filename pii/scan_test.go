@@ -0,0 +1,51 @@
+package pii_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+	"github.com/mble/pgdump-metadata-extractor/pii"
+)
+
+func TestScanner(t *testing.T) {
+	t.Parallel()
+
+	s := pii.NewScanner(pii.Builtin(), []string{"id", "contact"}, 2)
+	rows := []copydata.Row{
+		{{Value: "1"}, {Value: "alice@example.com"}},
+		{{Value: "2"}, {Null: true}},
+		{{Value: "3"}, {Value: "bob@example.com, carol@example.com"}},
+		{{Value: "4"}, {Value: "dave@example.com"}, {Value: "10.0.0.1"}},
+	}
+	for i, row := range rows {
+		s.Scan(row, int64(i*100))
+	}
+
+	want := pii.Table{
+		Rows: 4,
+		Hits: 5,
+		Findings: []pii.Finding{
+			{Column: "contact", Detector: pii.Email, Hits: 4, Offsets: []int64{0, 200}},
+			{Column: "column3", Detector: pii.IPv4, Hits: 1, Offsets: []int64{300}},
+		},
+	}
+	if got := s.Table(); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected=%+v, got=%+v", want, got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	r := pii.Report{}
+	r.Add(pii.Table{Tag: "orders", Rows: 10})
+	if !r.Clean() {
+		t.Error("expected a report without hits to be clean")
+	}
+
+	r.Add(pii.Table{Tag: "customers", Rows: 2, Hits: 3})
+	if r.Clean() || r.Hits != 3 {
+		t.Errorf("expected=%d hits, got=%d", 3, r.Hits)
+	}
+}
@@ -0,0 +1,71 @@
+package profile
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// hllPrecision is the number of hash bits choosing a register: 2^14
+// registers give a standard error of about 0.8%.
+const hllPrecision = 14
+
+// HyperLogLog estimates the number of distinct values added to it in
+// constant memory.
+type HyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+// Add records value.
+func (h *HyperLogLog) Add(value string) {
+	hash := hashString(value)
+
+	idx := hash >> (64 - hllPrecision)
+	// The rank is the position of the first set bit in the remaining bits,
+	// with a sentinel bit bounding it.
+	rest := hash<<hllPrecision | 1<<(hllPrecision-1)
+	rank := uint8(bits.LeadingZeros64(rest)) + 1
+
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Estimate returns the estimated number of distinct values added.
+func (h *HyperLogLog) Estimate() uint64 {
+	const m = float64(len(h.registers))
+
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more accurate for small cardinalities.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(math.Round(estimate))
+}
+
+// hashString hashes s with FNV-1a, finished with the splitmix64 mixer so
+// that every bit depends on the whole input.
+func hashString(s string) uint64 {
+	f := fnv.New64a()
+	_, _ = f.Write([]byte(s))
+	x := f.Sum64()
+
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
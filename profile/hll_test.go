@@ -0,0 +1,28 @@
+package profile_test
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/profile"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{0, 1, 100, 10_000, 200_000} {
+		h := &profile.HyperLogLog{}
+		for i := range n {
+			v := strconv.Itoa(i)
+			// Repeats must not count.
+			h.Add(v)
+			h.Add(v)
+		}
+
+		got := float64(h.Estimate())
+		if diff := math.Abs(got - float64(n)); diff > math.Max(1, 0.03*float64(n)) {
+			t.Errorf("n=%d: estimate %v is off by %v", n, got, diff)
+		}
+	}
+}
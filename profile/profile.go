@@ -0,0 +1,112 @@
+// Package profile computes per-column statistics of table data: NULL counts,
+// distinct value estimates, value lengths and inferred types.
+package profile
+
+import (
+	"unicode/utf8"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+)
+
+// Column is the profile of one column.
+type Column struct {
+	// Name is the column name, from the COPY statement.
+	Name string `json:"name"`
+	// Nulls is the number of NULL values.
+	Nulls int64 `json:"nulls"`
+	// Distinct is the estimated number of distinct non-NULL values.
+	Distinct uint64 `json:"distinct"`
+	// MinLength is the length in characters of the shortest non-NULL value.
+	MinLength int `json:"minLength"`
+	// MaxLength is the length in characters of the longest non-NULL value.
+	MaxLength int `json:"maxLength"`
+	// Type is the most specific type every non-NULL value is valid as, such
+	// as integer or timestamp, falling back to text. It is empty when every
+	// value is NULL.
+	Type string `json:"type,omitempty"`
+}
+
+// Table is the profile of a table's data.
+type Table struct {
+	// Namespace is the schema of the table.
+	Namespace string `json:"namespace,omitempty"`
+	// Tag is the name of the table.
+	Tag string `json:"tag"`
+	// Rows is the number of rows.
+	Rows int64 `json:"rows"`
+	// Columns are the column profiles, in table order.
+	Columns []Column `json:"columns"`
+}
+
+// columnState accumulates the profile of a column.
+type columnState struct {
+	Column
+	hll        HyperLogLog
+	candidates uint
+	values     int64
+}
+
+// Profiler builds the profile of a table row by row.
+type Profiler struct {
+	columns []string
+	states  []*columnState
+	rows    int64
+}
+
+// NewProfiler returns a Profiler for a table with columns. Without columns,
+// they are named column1, column2, … as rows with more values arrive.
+func NewProfiler(columns []string) *Profiler {
+	p := &Profiler{columns: columns}
+	for _, name := range columns {
+		p.states = append(p.states, newColumnState(name))
+	}
+
+	return p
+}
+
+func newColumnState(name string) *columnState {
+	return &columnState{Column: Column{Name: name}, candidates: allTypes}
+}
+
+// Add records a row.
+func (p *Profiler) Add(row copydata.Row) {
+	p.rows++
+
+	for len(p.states) < len(row) {
+		p.states = append(p.states, newColumnState(copydata.ColumnName(len(p.states))))
+	}
+
+	for i, field := range row {
+		s := p.states[i]
+		if field.Null {
+			s.Nulls++
+			continue
+		}
+
+		length := utf8.RuneCountInString(field.Value)
+		if s.values == 0 || length < s.MinLength {
+			s.MinLength = length
+		}
+		s.MaxLength = max(s.MaxLength, length)
+		s.values++
+
+		s.hll.Add(field.Value)
+		if s.candidates != 0 {
+			s.candidates = matchTypes(field.Value, s.candidates)
+		}
+	}
+}
+
+// Table returns the profile of the rows added so far.
+func (p *Profiler) Table() Table {
+	t := Table{Rows: p.rows, Columns: make([]Column, len(p.states))}
+	for i, s := range p.states {
+		t.Columns[i] = s.Column
+		if s.values > 0 {
+			t.Columns[i].Distinct = min(s.hll.Estimate(), uint64(s.values))
+			t.Columns[i].Type = typeName(s.candidates)
+		}
+	}
+
+	return t
+}
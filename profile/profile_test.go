@@ -0,0 +1,61 @@
+package profile_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/copydata"
+	"github.com/mble/pgdump-metadata-extractor/profile"
+)
+
+func v(s string) copydata.Field { return copydata.Field{Value: s} }
+
+var null = copydata.Field{Null: true}
+
+func TestProfiler(t *testing.T) {
+	t.Parallel()
+
+	p := profile.NewProfiler([]string{"id", "name", "active", "price", "ref", "born", "seen", "note"})
+	rows := []copydata.Row{
+		{v("1"), v("ålice"), v("t"), v("1.50"), v("0b8c2f1e-4a7d-4b8e-9c1a-2f3e4d5c6b7a"), v("1990-01-31"), v("2021-06-03 18:53:33+00"), null},
+		{v("2"), v("bob"), v("f"), v("2"), v("0B8C2F1E-4A7D-4B8E-9C1A-2F3E4D5C6B7B"), v("2001-12-01"), v("2021-06-03 18:53:33.123456"), null},
+		{v("3"), null, v("t"), v("-3e2"), null, null, v("2021-06-03 18:53:33+05:30"), null},
+		{v("3"), v("bob"), null, v("NaN"), null, null, null, null},
+	}
+	for _, row := range rows {
+		p.Add(row)
+	}
+
+	want := profile.Table{
+		Rows: 4,
+		Columns: []profile.Column{
+			{Name: "id", Distinct: 3, MinLength: 1, MaxLength: 1, Type: profile.TypeInteger},
+			{Name: "name", Nulls: 1, Distinct: 2, MinLength: 3, MaxLength: 5, Type: profile.TypeText},
+			{Name: "active", Nulls: 1, Distinct: 2, MinLength: 1, MaxLength: 1, Type: profile.TypeBoolean},
+			{Name: "price", Distinct: 4, MinLength: 1, MaxLength: 4, Type: profile.TypeNumeric},
+			{Name: "ref", Nulls: 2, Distinct: 2, MinLength: 36, MaxLength: 36, Type: profile.TypeUUID},
+			{Name: "born", Nulls: 2, Distinct: 2, MinLength: 10, MaxLength: 10, Type: profile.TypeDate},
+			{Name: "seen", Nulls: 1, Distinct: 3, MinLength: 22, MaxLength: 26, Type: profile.TypeTimestamp},
+			{Name: "note", Nulls: 4},
+		},
+	}
+	if got := p.Table(); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected=%+v, got=%+v", want, got)
+	}
+}
+
+func TestProfilerWithoutColumns(t *testing.T) {
+	t.Parallel()
+
+	p := profile.NewProfiler(nil)
+	p.Add(copydata.Row{v("1")})
+	p.Add(copydata.Row{v("2"), v("x")})
+
+	got := p.Table()
+	if len(got.Columns) != 2 || got.Columns[0].Name != "column1" || got.Columns[1].Name != "column2" {
+		t.Errorf("unexpected columns: %+v", got.Columns)
+	}
+	if got.Columns[1].Distinct != 1 || got.Columns[1].Type != profile.TypeText {
+		t.Errorf("unexpected column: %+v", got.Columns[1])
+	}
+}
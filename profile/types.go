@@ -0,0 +1,129 @@
+package profile
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types inferred for column values, from most to least specific.
+const (
+	TypeBoolean   = "boolean"
+	TypeInteger   = "integer"
+	TypeNumeric   = "numeric"
+	TypeUUID      = "uuid"
+	TypeDate      = "date"
+	TypeTimestamp = "timestamp"
+	TypeText      = "text"
+)
+
+// typeChecks are the inferred types other than text and how to recognise
+// their values as COPY writes them, in order of preference.
+var typeChecks = [...]struct {
+	name  string
+	check func(string) bool
+}{
+	{TypeBoolean, isBoolean},
+	{TypeInteger, isInteger},
+	{TypeNumeric, isNumeric},
+	{TypeUUID, isUUID},
+	{TypeDate, isDate},
+	{TypeTimestamp, isTimestamp},
+}
+
+// allTypes has a bit set for every entry of typeChecks.
+const allTypes = 1<<len(typeChecks) - 1
+
+// matchTypes returns the bits of the typeChecks value matches, out of those
+// in candidates.
+func matchTypes(value string, candidates uint) uint {
+	for i, tc := range typeChecks {
+		if candidates&(1<<i) != 0 && !tc.check(value) {
+			candidates &^= 1 << i
+		}
+	}
+
+	return candidates
+}
+
+// typeName returns the most specific type left in candidates.
+func typeName(candidates uint) string {
+	for i, tc := range typeChecks {
+		if candidates&(1<<i) != 0 {
+			return tc.name
+		}
+	}
+
+	return TypeText
+}
+
+func isBoolean(s string) bool {
+	return s == "t" || s == "f"
+}
+
+func isInteger(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+func isNumeric(s string) bool {
+	if s == "NaN" || s == "Infinity" || s == "-Infinity" {
+		return true
+	}
+	// ParseFloat accepts forms that numeric doesn't, such as hex and
+	// underscores, so check the characters first.
+	if strings.ContainsFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+' && r != 'e' && r != 'E'
+	}) {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+
+	return err == nil
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if s[i] != '-' {
+				return false
+			}
+		case !isHexDigit(s[i]):
+			return false
+		}
+	}
+
+	return true
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isDate(s string) bool {
+	_, err := time.Parse(time.DateOnly, s)
+	return err == nil
+}
+
+// timestampLayouts are the layouts COPY writes timestamps in with the default
+// ISO DateStyle, with and without a time zone, and with fractional seconds
+// accepted by Parse.
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05-07",
+	"2006-01-02 15:04:05-07:00",
+}
+
+func isTimestamp(s string) bool {
+	for _, layout := range timestampLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
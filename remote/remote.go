@@ -0,0 +1,317 @@
+// Package remote reads dumps over HTTP using Range requests.
+package remote
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrRangeUnsupported = errors.New("server does not support range requests")
+var ErrChanged = errors.New("remote object changed while reading")
+var ErrBadResponse = errors.New("unexpected response")
+
+const (
+	defaultBlockSize   = 64 << 10
+	defaultCacheBlocks = 64
+	defaultRetries     = 3
+	defaultBackoff     = 200 * time.Millisecond
+)
+
+// Options configures a Reader. Zero values select the defaults.
+type Options struct {
+	// Client is the HTTP client used for requests.
+	Client *http.Client
+	// BlockSize is the size of each ranged fetch, in bytes.
+	BlockSize int64
+	// CacheBlocks is the number of blocks kept in the LRU cache.
+	CacheBlocks int
+	// Retries is the number of times a failed fetch is retried.
+	Retries int
+	// Prepare, if set, is called on every request before it is sent, for
+	// example to sign it.
+	Prepare func(*http.Request) error
+	// Backoff is the delay before the first retry, doubling on each retry.
+	Backoff time.Duration
+}
+
+func (o *Options) withDefaults() Options {
+	out := *o
+	if out.Client == nil {
+		out.Client = http.DefaultClient
+	}
+	if out.BlockSize <= 0 {
+		out.BlockSize = defaultBlockSize
+	}
+	if out.CacheBlocks <= 0 {
+		out.CacheBlocks = defaultCacheBlocks
+	}
+	if out.Retries < 0 {
+		out.Retries = 0
+	} else if out.Retries == 0 {
+		out.Retries = defaultRetries
+	}
+	if out.Backoff <= 0 {
+		out.Backoff = defaultBackoff
+	}
+
+	return out
+}
+
+// Reader is an io.ReaderAt over a remote object, fetching fixed-size blocks
+// on demand with Range requests and caching recently used blocks.
+type Reader struct {
+	ctx   context.Context
+	cache map[int64]*list.Element
+	// inflight holds the fetches under way, so that concurrent reads of a
+	// block wait for one fetch instead of making their own.
+	inflight map[int64]*fetchCall
+	lru      *list.List
+	url      string
+	etag     string
+	opts     Options
+	size     int64
+	// mu guards cache, inflight and lru, and is never held during a fetch.
+	mu sync.Mutex
+}
+
+type block struct {
+	data  []byte
+	index int64
+}
+
+// fetchCall is a fetch of a block under way. done is closed once data and
+// err are set.
+type fetchCall struct {
+	done chan struct{}
+	err  error
+	data []byte
+}
+
+// Open probes url with a ranged GET for the first block, learning the
+// object's size. Only the bytes needed by subsequent reads are fetched.
+func Open(ctx context.Context, url string, opts Options) (*Reader, error) {
+	r := &Reader{
+		ctx:      ctx,
+		url:      url,
+		opts:     opts.withDefaults(),
+		cache:    make(map[int64]*list.Element),
+		inflight: make(map[int64]*fetchCall),
+		lru:      list.New(),
+		size:     -1,
+	}
+
+	if _, err := r.block(0); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Size returns the size of the remote object in bytes.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Name returns the URL of the remote object.
+func (r *Reader) Name() string {
+	return r.url
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset: %d", off)
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+
+		data, err := r.block(pos / r.opts.BlockSize)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], data[pos%r.opts.BlockSize:])
+	}
+
+	return n, nil
+}
+
+// block returns the block at index, from the cache or fetched. Only one
+// fetch of a block is made at a time; other readers of it wait for that one.
+func (r *Reader) block(index int64) ([]byte, error) {
+	r.mu.Lock()
+	if elem, ok := r.cache[index]; ok {
+		if cached, ok := elem.Value.(*block); ok {
+			r.lru.MoveToFront(elem)
+			r.mu.Unlock()
+			return cached.data, nil
+		}
+	}
+	if call, ok := r.inflight[index]; ok {
+		r.mu.Unlock()
+		<-call.done
+		return call.data, call.err
+	}
+	call := &fetchCall{done: make(chan struct{})}
+	r.inflight[index] = call
+	r.mu.Unlock()
+
+	call.data, call.err = r.fetch(index*r.opts.BlockSize, r.opts.BlockSize)
+
+	r.mu.Lock()
+	delete(r.inflight, index)
+	if call.err == nil {
+		r.store(index, call.data)
+	}
+	r.mu.Unlock()
+	close(call.done)
+
+	return call.data, call.err
+}
+
+// store caches data as the block at index, evicting the least recently used
+// block if the cache is full. r.mu must be held.
+func (r *Reader) store(index int64, data []byte) {
+	r.cache[index] = r.lru.PushFront(&block{index: index, data: data})
+	if r.lru.Len() > r.opts.CacheBlocks {
+		if oldest, ok := r.lru.Remove(r.lru.Back()).(*block); ok {
+			delete(r.cache, oldest.index)
+		}
+	}
+}
+
+// fetch retrieves length bytes at off, retrying transient failures with
+// exponential backoff.
+func (r *Reader) fetch(off, length int64) ([]byte, error) {
+	backoff := r.opts.Backoff
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var data []byte
+		var retry bool
+
+		data, retry, err = r.fetchOnce(off, length)
+		if err == nil {
+			return data, nil
+		}
+		if !retry || attempt >= r.opts.Retries {
+			return nil, err
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return nil, r.ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (r *Reader) fetchOnce(off, length int64) (data []byte, retry bool, err error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.url, http.NoBody)
+	if err != nil {
+		return nil, false, fmt.Errorf("err building request: %w", err)
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+length-1))
+	if r.etag != "" {
+		req.Header.Set("If-Match", r.etag)
+	}
+	if r.opts.Prepare != nil {
+		if err := r.opts.Prepare(req); err != nil {
+			return nil, false, fmt.Errorf("err preparing request: %w", err)
+		}
+	}
+
+	resp, err := r.opts.Client.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("err fetching %s: %w", r.url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && resp.ContentLength == 0 && r.size < 0:
+		// Servers may ignore ranges on an empty object.
+		r.size = 0
+		return []byte{}, false, nil
+	case resp.StatusCode == http.StatusOK:
+		return nil, false, ErrRangeUnsupported
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && r.size < 0:
+		// Only an empty object has no satisfiable first block.
+		if resp.Header.Get("Content-Range") != "bytes */0" {
+			return nil, false, fmt.Errorf("%w: %s", ErrBadResponse, resp.Status)
+		}
+		r.size = 0
+		return []byte{}, false, nil
+	case resp.StatusCode == http.StatusPreconditionFailed:
+		return nil, false, ErrChanged
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return nil, true, fmt.Errorf("%w: %s", ErrBadResponse, resp.Status)
+	default:
+		return nil, false, fmt.Errorf("%w: %s", ErrBadResponse, resp.Status)
+	}
+
+	start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, false, err
+	}
+	if start != off {
+		return nil, false, fmt.Errorf("%w: range starts at %d, requested %d", ErrBadResponse, start, off)
+	}
+
+	if r.size < 0 {
+		r.size = total
+		r.etag = resp.Header.Get("ETag")
+	} else if total != r.size {
+		return nil, false, ErrChanged
+	}
+
+	want := min(length, r.size-off)
+	data = make([]byte, want)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, true, fmt.Errorf("err reading %s: %w", r.url, err)
+	}
+
+	return data, false, nil
+}
+
+// parseContentRange parses a "bytes start-end/total" header value.
+func parseContentRange(value string) (start, total int64, err error) {
+	spec, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: content-range %q", ErrBadResponse, value)
+	}
+
+	rng, size, ok := strings.Cut(spec, "/")
+	if !ok || size == "*" {
+		return 0, 0, fmt.Errorf("%w: content-range %q", ErrBadResponse, value)
+	}
+
+	first, _, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%w: content-range %q", ErrBadResponse, value)
+	}
+
+	if start, err = strconv.ParseInt(first, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("%w: content-range %q", ErrBadResponse, value)
+	}
+	if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("%w: content-range %q", ErrBadResponse, value)
+	}
+
+	return start, total, nil
+}
@@ -0,0 +1,285 @@
+package remote_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+	"github.com/mble/pgdump-metadata-extractor/remote"
+)
+
+func serve(t *testing.T, data []byte, requests *atomic.Int64) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "latest.dump", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestReaderHeaderOnly(t *testing.T) {
+	t.Parallel()
+
+	header, err := os.ReadFile("../testdata/min.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(header, make([]byte, 1<<20)...)
+
+	var requests atomic.Int64
+	srv := serve(t, data, &requests)
+
+	r, err := remote.Open(context.Background(), srv.URL, remote.Options{BlockSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Size() != int64(len(data)) {
+		t.Errorf("expected=%d, got=%d", len(data), r.Size())
+	}
+
+	meta, err := metadata.NewMetadata(io.NewSectionReader(r, 0, r.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TOCCount != 15 {
+		t.Errorf("expected=%d, got=%d", 15, meta.TOCCount)
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected a single ranged request, got=%d", got)
+	}
+}
+
+func TestReaderReadAt(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	var requests atomic.Int64
+	srv := serve(t, data, &requests)
+
+	r, err := remote.Open(context.Background(), srv.URL, remote.Options{BlockSize: 1024, CacheBlocks: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 3000)
+	n, err := r.ReadAt(buf, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(buf) || !bytes.Equal(buf, data[500:3500]) {
+		t.Errorf("read mismatch at 500")
+	}
+
+	n, err = r.ReadAt(buf, 9000)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("expected=%v, got=%v", io.EOF, err)
+	}
+	if n != 1000 || !bytes.Equal(buf[:n], data[9000:]) {
+		t.Errorf("read mismatch at 9000")
+	}
+
+	before := requests.Load()
+	if _, err := r.ReadAt(buf[:10], 9500); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != before {
+		t.Errorf("expected cached block to be reused")
+	}
+}
+
+func TestReaderConcurrent(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	var blockOne atomic.Int64
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "bytes=1024-2047" {
+			if blockOne.Add(1) == 1 {
+				close(started)
+			}
+			<-release
+		}
+		http.ServeContent(w, r, "latest.dump", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+
+	r, err := remote.Open(context.Background(), srv.URL, remote.Options{BlockSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			buf := make([]byte, 1024)
+			_, err := r.ReadAt(buf, 1024)
+			if err == nil && !bytes.Equal(buf, data[1024:2048]) {
+				err = errors.New("read mismatch at 1024")
+			}
+			errs <- err
+		}()
+	}
+	<-started
+
+	// A cached block is served while another is being fetched.
+	cached := make(chan error, 1)
+	go func() {
+		_, err := r.ReadAt(make([]byte, 10), 0)
+		cached <- err
+	}()
+	select {
+	case err := <-cached:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cached read blocked behind a fetch")
+	}
+
+	close(release)
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if got := blockOne.Load(); got != 1 {
+		t.Errorf("expected a single fetch of the block, got=%d", got)
+	}
+}
+
+func TestReaderRetries(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+	data := []byte("PGDMP")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeContent(w, r, "latest.dump", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+
+	r, err := remote.Open(context.Background(), srv.URL, remote.Options{Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(data)) {
+		t.Errorf("expected=%d, got=%d", len(data), r.Size())
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("expected=%d, got=%d", 3, got)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		err     error
+		handler http.HandlerFunc
+		desc    string
+	}{
+		{
+			desc: "no range support",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("PGDMP"))
+			},
+			err: remote.ErrRangeUnsupported,
+		},
+		{
+			desc: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			},
+			err: remote.ErrBadResponse,
+		},
+		{
+			desc: "retries exhausted",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, "down", http.StatusBadGateway)
+			},
+			err: remote.ErrBadResponse,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(tC.handler)
+			t.Cleanup(srv.Close)
+
+			_, err := remote.Open(context.Background(), srv.URL, remote.Options{Retries: 1, Backoff: time.Millisecond})
+			if !errors.Is(err, tC.err) {
+				t.Errorf("expected=%v, got=%v", tC.err, err)
+			}
+		})
+	}
+}
+
+func TestReaderChanged(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 4096)
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("ETag", `"v1"`)
+		} else {
+			w.Header().Set("ETag", `"v2"`)
+		}
+		http.ServeContent(w, r, "latest.dump", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+
+	r, err := remote.Open(context.Background(), srv.URL, remote.Options{BlockSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.ReadAt(make([]byte, 10), 2048)
+	if !errors.Is(err, remote.ErrChanged) {
+		t.Errorf("expected=%v, got=%v", remote.ErrChanged, err)
+	}
+}
+
+func TestReaderEmpty(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int64
+	srv := serve(t, []byte{}, &requests)
+
+	r, err := remote.Open(context.Background(), srv.URL, remote.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = metadata.NewMetadata(io.NewSectionReader(r, 0, r.Size()))
+	if !errors.Is(err, metadata.ErrNeedMoreData) {
+		t.Errorf("expected=%v, got=%v", metadata.ErrNeedMoreData, err)
+	}
+}
@@ -0,0 +1,207 @@
+// Package s3 reads dumps from S3-compatible object storage.
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/remote"
+)
+
+var ErrInvalidURL = errors.New("invalid s3 URL")
+var ErrMissingCredentials = errors.New("missing s3 credentials")
+var ErrListFailed = errors.New("err listing bucket")
+
+const defaultRegion = "us-east-1"
+
+// Object is an object in a bucket listing.
+type Object struct {
+	LastModified time.Time `xml:"LastModified"`
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+}
+
+// Client talks to an S3-compatible endpoint.
+type Client struct {
+	// HTTP is the HTTP client used for requests.
+	HTTP *http.Client
+	// Now returns the time requests are signed at.
+	Now func() time.Time
+	// Credentials sign every request.
+	Credentials Credentials
+	// Region is the signing region.
+	Region string
+	// Endpoint overrides the AWS endpoint, e.g. "http://localhost:9000" for a
+	// MinIO-style stand-in. Buckets are then addressed path-style.
+	Endpoint string
+}
+
+// NewClientFromEnv returns a client using the standard AWS_* environment
+// variables, with endpoint and region overriding them when set.
+func NewClientFromEnv(endpoint, region string) (*Client, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%w: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", ErrMissingCredentials)
+	}
+
+	for _, candidate := range []string{region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), defaultRegion} {
+		if candidate != "" {
+			region = candidate
+			break
+		}
+	}
+
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL_S3")
+	}
+
+	return &Client{
+		HTTP:        http.DefaultClient,
+		Now:         time.Now,
+		Credentials: creds,
+		Region:      region,
+		Endpoint:    endpoint,
+	}, nil
+}
+
+// IsURL reports whether name is an s3:// URL.
+func IsURL(name string) bool {
+	return strings.HasPrefix(name, "s3://")
+}
+
+// IsPrefixURL reports whether name is an s3:// URL naming a bucket or a key
+// prefix ending in '/', rather than a single object.
+func IsPrefixURL(name string) bool {
+	_, key, err := ParseURL(name)
+	return err == nil && (key == "" || strings.HasSuffix(key, "/"))
+}
+
+// ParseURL splits an s3://bucket/key URL into its bucket and key. The key
+// may be empty or end in '/' to denote a prefix.
+func ParseURL(name string) (bucket, key string, err error) {
+	rest, ok := strings.CutPrefix(name, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", ErrInvalidURL, name)
+	}
+
+	bucket, key, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("%w: no bucket in %s", ErrInvalidURL, name)
+	}
+
+	return bucket, key, nil
+}
+
+// Open returns a ranged reader over the object at bucket/key.
+func (c *Client) Open(ctx context.Context, bucket, key string) (*remote.Reader, error) {
+	return remote.Open(ctx, c.objectURL(bucket, key, nil).String(), remote.Options{
+		Client:  c.HTTP,
+		Prepare: c.sign,
+	})
+}
+
+// List returns every object in bucket whose key starts with prefix.
+func (c *Client) List(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		page, err := c.listPage(ctx, bucket, query)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+type listBucketResult struct {
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+	Contents              []Object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+}
+
+func (c *Client) listPage(ctx context.Context, bucket string, query url.Values) (*listBucketResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.objectURL(bucket, "", query).String(), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("err building request: %w", err)
+	}
+	if err = c.sign(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrListFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%w: %s: %s", ErrListFailed, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	page := &listBucketResult{}
+	if err := xml.NewDecoder(resp.Body).Decode(page); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrListFailed, err)
+	}
+
+	return page, nil
+}
+
+func (c *Client) sign(req *http.Request) error {
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+
+	Sign(req, &c.Credentials, c.Region, now())
+
+	return nil
+}
+
+// objectURL addresses bucket/key virtual-hosted style on AWS, and path style
+// on an overridden endpoint or for bucket names that can't be hostnames.
+func (c *Client) objectURL(bucket, key string, query url.Values) *url.URL {
+	u := &url.URL{Scheme: "https", RawQuery: query.Encode()}
+
+	switch {
+	case c.Endpoint != "":
+		if endpoint, err := url.Parse(c.Endpoint); err == nil {
+			u.Scheme = endpoint.Scheme
+			u.Host = endpoint.Host
+		}
+		u.Path = "/" + bucket + "/" + key
+	case strings.Contains(bucket, "."):
+		u.Host = fmt.Sprintf("s3.%s.amazonaws.com", c.Region)
+		u.Path = "/" + bucket + "/" + key
+	default:
+		u.Host = fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, c.Region)
+		u.Path = "/" + key
+	}
+
+	u.RawPath = uriEncode(u.Path, false)
+
+	return u
+}
@@ -0,0 +1,183 @@
+package s3_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+	"github.com/mble/pgdump-metadata-extractor/s3"
+)
+
+// fakeS3 serves objects path-style, one listing entry per page.
+func fakeS3(t *testing.T, bucket string, objects map[string][]byte) *httptest.Server {
+	t.Helper()
+
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+
+		key, ok := strings.CutPrefix(r.URL.Path, "/"+bucket+"/")
+		if !ok {
+			http.Error(w, "NoSuchBucket", http.StatusNotFound)
+			return
+		}
+
+		if r.URL.Query().Get("list-type") == "2" {
+			listPage(w, r, keys, objects)
+			return
+		}
+
+		data, ok := objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func listPage(w http.ResponseWriter, r *http.Request, keys []string, objects map[string][]byte) {
+	prefix := r.URL.Query().Get("prefix")
+	start, _ := strconv.Atoi(r.URL.Query().Get("continuation-token"))
+
+	var matching []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			matching = append(matching, key)
+		}
+	}
+
+	fmt.Fprint(w, "<ListBucketResult>")
+	if start < len(matching) {
+		key := matching[start]
+		fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", key, len(objects[key]))
+	}
+	if start+1 < len(matching) {
+		fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>", start+1)
+	} else {
+		fmt.Fprint(w, "<IsTruncated>false</IsTruncated>")
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+func newClient(endpoint string) *s3.Client {
+	return &s3.Client{
+		HTTP:        http.DefaultClient,
+		Credentials: s3.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		Region:      "us-east-1",
+		Endpoint:    endpoint,
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		err    error
+		desc   string
+		url    string
+		bucket string
+		key    string
+	}{
+		{desc: "object", url: "s3://backups/bigdb/latest.dump", bucket: "backups", key: "bigdb/latest.dump"},
+		{desc: "prefix", url: "s3://backups/bigdb/", bucket: "backups", key: "bigdb/"},
+		{desc: "bucket only", url: "s3://backups", bucket: "backups", key: ""},
+		{desc: "no bucket", url: "s3:///latest.dump", err: s3.ErrInvalidURL},
+		{desc: "wrong scheme", url: "https://backups/latest.dump", err: s3.ErrInvalidURL},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			bucket, key, err := s3.ParseURL(tC.url)
+			if !errors.Is(err, tC.err) {
+				t.Fatalf("expected=%v, got=%v", tC.err, err)
+			}
+			if bucket != tC.bucket || key != tC.key {
+				t.Errorf("expected=%s/%s, got=%s/%s", tC.bucket, tC.key, bucket, key)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	dump, err := os.ReadFile("../testdata/min.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := fakeS3(t, "backups", map[string][]byte{"bigdb/latest.dump": dump})
+
+	r, err := newClient(srv.URL).Open(context.Background(), "backups", "bigdb/latest.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := metadata.NewMetadata(io.NewSectionReader(r, 0, r.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *meta.DatabaseName != "empty_db" {
+		t.Errorf("expected=%s, got=%s", "empty_db", *meta.DatabaseName)
+	}
+}
+
+func TestOpenDenied(t *testing.T) {
+	t.Parallel()
+
+	srv := fakeS3(t, "backups", map[string][]byte{"latest.dump": []byte("PGDMP")})
+	client := newClient(srv.URL)
+	client.Credentials.AccessKeyID = "WRONG"
+
+	_, err := client.Open(context.Background(), "backups", "latest.dump")
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	srv := fakeS3(t, "backups", map[string][]byte{
+		"bigdb/2021-06-01.dump": []byte("one"),
+		"bigdb/2021-06-02.dump": []byte("two"),
+		"bigdb/2021-06-03.dump": []byte("three"),
+		"other/2021-06-03.dump": []byte("four"),
+	})
+
+	objects, err := newClient(srv.URL).List(context.Background(), "backups", "bigdb/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(objects) != 3 {
+		t.Fatalf("expected=%d, got=%d", 3, len(objects))
+	}
+	if objects[2].Key != "bigdb/2021-06-03.dump" || objects[2].Size != 5 {
+		t.Errorf("unexpected object: %+v", objects[2])
+	}
+}
@@ -0,0 +1,187 @@
+// Package server exposes the extractor over HTTP.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/extractor"
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+var ErrInvalidConfig = errors.New("invalid config")
+
+// Cfg holds the config for the server.
+type Cfg struct {
+	// Addr is the TCP address to listen on.
+	Addr string
+	// MaxBytes is the maximum number of request body bytes read per request.
+	MaxBytes int64
+	// Timeout bounds the time taken to read and answer a request.
+	Timeout time.Duration
+}
+
+// Validate ensures that Cfg struct is valid.
+func (c *Cfg) Validate() error {
+	if c.Addr == "" {
+		return fmt.Errorf("%w: address not specified", ErrInvalidConfig)
+	}
+
+	if c.MaxBytes <= 0 {
+		return fmt.Errorf("%w: max bytes must be positive", ErrInvalidConfig)
+	}
+
+	if c.Timeout <= 0 {
+		return fmt.Errorf("%w: timeout must be positive", ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// NewHandler returns the handler serving POST /inspect and GET /healthz,
+// logging every request to logger.
+func NewHandler(cfg *Cfg, logger *slog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /inspect", inspect(cfg))
+	mux.HandleFunc("GET /healthz", healthz)
+
+	return accessLog(logger, http.TimeoutHandler(mux, cfg.Timeout, `{"error":"request timed out"}`))
+}
+
+// Run serves until ctx is cancelled, then shuts down gracefully.
+func Run(ctx context.Context, cfg *Cfg, logger *slog.Logger) error {
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           NewHandler(cfg, logger),
+		ReadHeaderTimeout: cfg.Timeout,
+		ReadTimeout:       cfg.Timeout,
+		WriteTimeout:      cfg.Timeout + time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("listening", slog.String("addr", cfg.Addr))
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("err serving: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("err shutting down: %w", err)
+	}
+
+	return nil
+}
+
+// inspect streams the request body into the extractor, which stops reading
+// as soon as the header has been parsed.
+func inspect(cfg *Cfg) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := http.MaxBytesReader(w, r.Body, cfg.MaxBytes)
+
+		data, err := extractor.Extract(body)
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+
+		out, err := data.ToJSON()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(append(out, '\n'))
+	}
+}
+
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
+
+func statusFor(err error) int {
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, metadata.ErrNotADump),
+		errors.Is(err, metadata.ErrNeedMoreData),
+		errors.Is(err, metadata.ErrInvalidIntSize),
+		errors.Is(err, metadata.ErrInvalidOffSize),
+		errors.Is(err, metadata.ErrIntOverflow),
+		errors.Is(err, metadata.ErrStringTooLarge):
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusBadRequest
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	out, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(out, '\n'))
+}
+
+// statusRecorder captures the status and size of a response for logging.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+
+	n, err := s.ResponseWriter.Write(p)
+	s.bytes += n
+
+	return n, err
+}
+
+func accessLog(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("remote", r.RemoteAddr),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Int64("requestBytes", r.ContentLength),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
@@ -0,0 +1,217 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mble/pgdump-metadata-extractor/server"
+)
+
+// syncBuffer is a bytes.Buffer safe to write from the server and read from the test.
+type syncBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.buf.String()
+}
+
+func newServer(t *testing.T, logs io.Writer) *httptest.Server {
+	t.Helper()
+
+	cfg := server.Cfg{Addr: ":0", MaxBytes: 1 << 20, Timeout: 5 * time.Second}
+	logger := slog.New(slog.NewJSONHandler(logs, nil))
+	srv := httptest.NewServer(server.NewHandler(&cfg, logger))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestCfgValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		err    error
+		desc   string
+		config server.Cfg
+	}{
+		{
+			desc:   "no address",
+			config: server.Cfg{MaxBytes: 1, Timeout: time.Second},
+			err:    server.ErrInvalidConfig,
+		},
+		{
+			desc:   "no size limit",
+			config: server.Cfg{Addr: ":8080", Timeout: time.Second},
+			err:    server.ErrInvalidConfig,
+		},
+		{
+			desc:   "no timeout",
+			config: server.Cfg{Addr: ":8080", MaxBytes: 1},
+			err:    server.ErrInvalidConfig,
+		},
+		{
+			desc:   "valid",
+			config: server.Cfg{Addr: ":8080", MaxBytes: 1, Timeout: time.Second},
+			err:    nil,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res := tC.config.Validate()
+			if !errors.Is(res, tC.err) {
+				t.Errorf("expected=%v, got=%v", tC.err, res)
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	var logs syncBuffer
+	srv := newServer(t, &logs)
+
+	data, err := os.ReadFile("../testdata/min.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Post(srv.URL+"/inspect", "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected=%d, got=%d", http.StatusOK, resp.StatusCode)
+	}
+
+	var res struct {
+		Database string `json:"database"`
+		TOCCount int    `json:"toccount"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Database != "empty_db" || res.TOCCount != 15 {
+		t.Errorf("unexpected response: %+v", res)
+	}
+
+	if !strings.Contains(logs.String(), `"path":"/inspect","remote"`) {
+		t.Errorf("expected access log, got=%s", logs.String())
+	}
+}
+
+func TestInspectErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer(t, io.Discard)
+
+	testCases := []struct {
+		desc   string
+		method string
+		body   string
+		status int
+	}{
+		{
+			desc:   "not a dump",
+			method: http.MethodPost,
+			body:   "this is not a dump",
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			desc:   "truncated",
+			method: http.MethodPost,
+			body:   "PGDMP",
+			status: http.StatusUnprocessableEntity,
+		},
+		{
+			desc:   "wrong method",
+			method: http.MethodGet,
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := http.NewRequest(tC.method, srv.URL+"/inspect", strings.NewReader(tC.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tC.status {
+				t.Errorf("expected=%d, got=%d", tC.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestInspectTooLarge(t *testing.T) {
+	t.Parallel()
+
+	cfg := server.Cfg{Addr: ":0", MaxBytes: 8, Timeout: 5 * time.Second}
+	srv := httptest.NewServer(server.NewHandler(&cfg, slog.New(slog.NewJSONHandler(io.Discard, nil))))
+	t.Cleanup(srv.Close)
+
+	data, err := os.ReadFile("../testdata/min.dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Post(srv.URL+"/inspect", "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected=%d, got=%d", http.StatusRequestEntityTooLarge, resp.StatusCode)
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	t.Parallel()
+
+	srv := newServer(t, io.Discard)
+
+	resp, err := http.Get(srv.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected=%d, got=%d", http.StatusOK, resp.StatusCode)
+	}
+}
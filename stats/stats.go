@@ -0,0 +1,50 @@
+// Package stats summarises what a dump contains from its table of contents.
+package stats
+
+import (
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+)
+
+// Summary counts the TOC entries of a dump.
+type Summary struct {
+	// Entries is the total number of TOC entries.
+	Entries int `json:"entries"`
+	// ByType counts entries by object type, such as TABLE or INDEX.
+	ByType map[string]int `json:"byType"`
+	// BySchema counts entries by schema. Objects outside any schema, such as
+	// schemas and extensions themselves, are not counted.
+	BySchema map[string]int `json:"bySchema"`
+	// ByOwner counts entries by owner. Entries without an owner, such as
+	// comments and ACLs, are not counted.
+	ByOwner map[string]int `json:"byOwner"`
+	// BySection counts entries by the section they are restored in.
+	BySection map[string]int `json:"bySection"`
+}
+
+// Summarize counts entries by type, schema, owner and section.
+func Summarize(entries []metadata.TOCEntry) *Summary {
+	s := &Summary{
+		Entries:   len(entries),
+		ByType:    make(map[string]int),
+		BySchema:  make(map[string]int),
+		ByOwner:   make(map[string]int),
+		BySection: make(map[string]int),
+	}
+
+	for i := range entries {
+		e := &entries[i]
+
+		s.ByType[e.Desc]++
+		if e.Namespace != "" {
+			s.BySchema[e.Namespace]++
+		}
+		if e.Owner != "" {
+			s.ByOwner[e.Owner]++
+		}
+		if e.Section != "" {
+			s.BySection[e.Section]++
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,43 @@
+package stats_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mble/pgdump-metadata-extractor/metadata"
+	"github.com/mble/pgdump-metadata-extractor/stats"
+)
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	entries := []metadata.TOCEntry{
+		{Desc: "SCHEMA", Tag: "sales", Owner: "admin", Section: "PRE-DATA"},
+		{Desc: "TABLE", Tag: "orders", Namespace: "sales", Owner: "shop", Section: "PRE-DATA"},
+		{Desc: "TABLE", Tag: "users", Namespace: "public", Owner: "shop", Section: "PRE-DATA"},
+		{Desc: "TABLE DATA", Tag: "orders", Namespace: "sales", Owner: "shop", Section: "DATA"},
+		{Desc: "INDEX", Tag: "orders_pkey", Namespace: "sales", Owner: "shop", Section: "POST-DATA"},
+		{Desc: "COMMENT", Tag: "TABLE orders", Namespace: "sales", Section: "NONE"},
+	}
+
+	got := stats.Summarize(entries)
+	want := &stats.Summary{
+		Entries:   6,
+		ByType:    map[string]int{"SCHEMA": 1, "TABLE": 2, "TABLE DATA": 1, "INDEX": 1, "COMMENT": 1},
+		BySchema:  map[string]int{"sales": 4, "public": 1},
+		ByOwner:   map[string]int{"admin": 1, "shop": 4},
+		BySection: map[string]int{"PRE-DATA": 3, "DATA": 1, "POST-DATA": 1, "NONE": 1},
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("expected=%+v, got=%+v", want, got)
+	}
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	t.Parallel()
+
+	got := stats.Summarize(nil)
+	if got.Entries != 0 || got.ByType == nil || len(got.ByType) != 0 {
+		t.Errorf("expected an empty summary, got=%+v", got)
+	}
+}